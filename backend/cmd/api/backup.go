@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/backup"
+)
+
+// Returns the collections included in backups. Uploaded files are not part of the
+// archive; copy MEDIA_DIR alongside it.
+func backupCollections(client *mongo.Client) []backup.Collection {
+	db := client.Database(dbName)
+	collections := []backup.Collection{
+		{Name: "admins", Collection: client.Database(adminDBName).Collection("admins")},
+	}
+	for _, name := range []string{"users", "posts", "comments", "media", "reactions", "follows", "notifications"} {
+		collections = append(collections, backup.Collection{Name: name, Collection: db.Collection(name)})
+	}
+	return collections
+}
+
+// Handles "api backup [-o file]"
+func runBackup(client *mongo.Client, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "backup-"+time.Now().UTC().Format("20060102-150405")+".tar.gz", "archive to write")
+	flags.Parse(args)
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	manifest, err := backup.Write(ctx, backupCollections(client), f)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(*output) // Don't leave a truncated archive that looks usable
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	slog.Info("Wrote backup", "file", *output, "contents", summary(manifest))
+	return nil
+}
+
+// Handles "api restore file"
+func runRestore(client *mongo.Client, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: api restore <archive.tar.gz>")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	manifest, err := backup.Restore(ctx, backupCollections(client), f)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	slog.Info("Restored backup", "created_at", manifest.CreatedAt.Format(time.RFC3339), "contents", summary(manifest))
+	return nil
+}
+
+func summary(manifest *backup.Manifest) string {
+	names := make([]string, 0, len(manifest.Collections))
+	for name := range manifest.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := ""
+	for i, name := range names {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d %s", manifest.Collections[name], name)
+	}
+	return s
+}
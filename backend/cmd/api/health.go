@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/health"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+)
+
+// Registers the readiness checks for the database the server depends on
+func addReadinessChecks(checker *health.Checker, client *mongo.Client) {
+	checker.Add("mongo", func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+
+	// Unready until the startup index build has finished, since queries would scan whole
+	// collections meanwhile. Indexes it couldn't build, such as a unique index over
+	// duplicate values, need an operator rather than a restart, so they only degrade it.
+	db := client.Database(dbName)
+	checker.Add("indexes", func(ctx context.Context) error {
+		select {
+		case <-indexesBuilt:
+		default:
+			return errors.New("indexes are still being built")
+		}
+		diffs, err := repository.DiffIndexes(ctx, db)
+		if err != nil {
+			return err
+		}
+		var pending []string
+		for _, diff := range diffs {
+			if diff.Kind != repository.IndexExtra {
+				pending = append(pending, diff.String())
+			}
+		}
+		if len(pending) > 0 {
+			return health.Degraded(fmt.Errorf("indexes not built: %s", strings.Join(pending, "; ")))
+		}
+		return nil
+	})
+}
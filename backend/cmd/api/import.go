@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/importer"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+)
+
+// Parsers for the platforms "api import-<name>" understands
+var importFormats = map[string]func(io.Reader) (*importer.Site, error){
+	"wordpress": importer.ParseWordPress,
+	"ghost":     importer.ParseGhost,
+}
+
+// Handles "api import-wordpress|import-ghost [-dry-run | -credentials file] export"
+func runImport(client *mongo.Client, format string, args []string) error {
+	flags := flag.NewFlagSet("import-"+format, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without writing anything")
+	credentialsPath := flags.String("credentials", "", "new `file` to write the temporary passwords of created users to")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: api import-%s [-dry-run | -credentials <file>] <export file>\n", format)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || (!*dryRun && *credentialsPath == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	site, err := importFormats[format](f)
+	if err != nil {
+		return err
+	}
+
+	// Created before importing so a bad path fails before anything is written. Only the
+	// operator may read it, and an existing file is never overwritten.
+	var credentials *os.File
+	if !*dryRun {
+		credentials, err = os.OpenFile(*credentialsPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer credentials.Close()
+	}
+
+	db := client.Database(dbName)
+	im := importer.New(repository.NewUserRepository(db), repository.NewPostRepository(db), repository.NewCommentRepository(db))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	report, err := im.Import(ctx, site, *dryRun)
+	if credentials != nil {
+		if writeErr := writeCredentials(credentials, report); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	printImportReport(report, *credentialsPath)
+	return err
+}
+
+// Writes "username password" lines for the users the import created
+func writeCredentials(f *os.File, report *importer.Report) error {
+	for _, username := range report.UsersCreated {
+		if password, ok := report.Passwords[username]; ok {
+			if _, err := fmt.Fprintf(f, "%s %s\n", username, password); err != nil {
+				return err
+			}
+		}
+	}
+	return f.Close()
+}
+
+func printImportReport(report *importer.Report, credentialsPath string) {
+	if report.DryRun {
+		fmt.Println("Dry run; nothing was written.")
+	}
+	fmt.Printf("Users:    %d created, %d already imported\n", len(report.UsersCreated), report.UsersExisting)
+	fmt.Printf("Posts:    %d created, %d already imported, %d skipped\n", report.PostsCreated, report.PostsExisting, report.PostsSkipped)
+	fmt.Printf("Comments: %d created, %d already imported\n", report.CommentsCreated, report.CommentsExisting)
+	for _, username := range report.UsersCreated {
+		if _, ok := report.Passwords[username]; ok {
+			fmt.Printf("Created user %s; temporary password written to %s\n", username, credentialsPath)
+		} else {
+			fmt.Printf("Would create user %s\n", username)
+		}
+	}
+	for _, warning := range report.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+)
+
+// Closed once the startup index build has finished, whether or not every index was built
+var indexesBuilt = make(chan struct{})
+
+// Builds missing or changed indexes in the background, so the server can start while a
+// large collection is indexed; readiness fails until it is done
+func ensureIndexes(ctx context.Context, client *mongo.Client) {
+	defer close(indexesBuilt)
+	ctx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	if err := repository.EnsureIndexes(ctx, client.Database(dbName)); err != nil {
+		slog.Error("Failed to build indexes; run \"api indexes\" to list duplicate keys", "error", err)
+	}
+}
+
+// Handles "api indexes [-apply]"
+func runIndexes(client *mongo.Client, args []string) error {
+	flags := flag.NewFlagSet("indexes", flag.ExitOnError)
+	apply := flags.Bool("apply", false, "build missing and changed indexes instead of only listing them")
+	flags.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	db := client.Database(dbName)
+	if *apply {
+		if err := repository.EnsureIndexes(ctx, db); err != nil {
+			return err
+		}
+	}
+
+	diffs, err := repository.DiffIndexes(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Println("Indexes match the declarations")
+		return nil
+	}
+	for _, diff := range diffs {
+		fmt.Println(diff)
+		if diff.Kind == repository.IndexExtra {
+			continue
+		}
+		// A unique index can't be built until these are resolved by hand
+		duplicates, err := repository.Duplicates(ctx, db, diff.Collection, diff.Name, 20)
+		if err != nil {
+			return err
+		}
+		for _, duplicate := range duplicates {
+			fmt.Printf("  %s is shared by %d documents\n", duplicate.Key, duplicate.Count)
+		}
+	}
+	return nil
+}
@@ -2,123 +2,403 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 
-	"github.com/DavAnders/odin-blogapi/backend/internal/api/controller"
-	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/openapi"
+	"github.com/DavAnders/odin-blogapi/backend/internal/cache"
+	"github.com/DavAnders/odin-blogapi/backend/internal/health"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/metrics"
+	"github.com/DavAnders/odin-blogapi/backend/internal/realtime"
 	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
+	"github.com/DavAnders/odin-blogapi/backend/internal/storage"
+	"github.com/DavAnders/odin-blogapi/backend/internal/tracing"
 )
 
+// Databases holding the blog's content and the admin list
+const (
+	dbName      = "blogprod"
+	adminDBName = "blog"
+)
+
+const usage = `usage: api [command]
+
+Commands:
+  serve             Run the HTTP server (default)
+  backup            Write the database to an archive
+  restore           Load an archive into an empty database
+  import-markdown   Create or update posts from Markdown files
+  export-markdown   Write every post to a Markdown file
+  import-wordpress  Import a WordPress WXR export
+  import-ghost      Import a Ghost JSON export
+  check-openapi     Compare the OpenAPI document with the routes and models
+  generate-client   Write the frontend's API client from the OpenAPI document
+  indexes           Show how the database's indexes differ from the declared ones
+  migrate           Show, apply or revert database migrations
+`
+
 func main() {
-	// Load .env file
+	// Load .env file; commands that don't touch the database can run without one
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		log.Fatal("Error loading .env file:", err)
 	}
 
+	// Logging is configured before anything else so every command logs the same way
+	logger, err := logging.New(os.Stderr, logging.Options{Level: os.Getenv("LOG_LEVEL"), Format: os.Getenv("LOG_FORMAT")})
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	command, args := "serve", []string{}
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
+
+	switch command {
+	case "serve":
+		serve(connect())
+	case "backup":
+		err = runBackup(connect(), args)
+	case "restore":
+		err = runRestore(connect(), args)
+	case "import-markdown":
+		err = runImportMarkdown(connect(), args)
+	case "export-markdown":
+		err = runExportMarkdown(connect(), args)
+	case "import-wordpress":
+		err = runImport(connect(), "wordpress", args)
+	case "import-ghost":
+		err = runImport(connect(), "ghost", args)
+	case "check-openapi":
+		err = runCheckOpenAPI()
+	case "generate-client":
+		err = runGenerateClient(args)
+	case "indexes":
+		err = runIndexes(connect(), args)
+	case "migrate":
+		err = runMigrate(connect(), args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal("Command failed", "command", command, "error", err)
+	}
+}
+
+// Logs msg at error level and exits
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Connects to MongoDB using MONGO_URI
+func connect() *mongo.Client {
 	mongoURI := os.Getenv("MONGO_URI")
 	if mongoURI == "" {
-		log.Fatal("MONGO_URI is not set in .env file")
+		fatal("MONGO_URI is not set in .env file")
 	}
 
 	// Connect to MongoDB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	monitor := combineMonitors(metrics.CommandMonitor(), otelmongo.NewMonitor())
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI).SetMonitor(monitor))
 	if err != nil {
-		log.Fatal("Failed to connect to MongoDB:", err)
+		fatal("Failed to connect to MongoDB", "error", err)
 	}
 
 	// Check the connection
 	if err = client.Ping(ctx, nil); err != nil {
-		log.Fatal("Failed to ping MongoDB:", err)
-	}
-	log.Println("Connected to MongoDB")
-
-	// Initialize repositories
-	postRepo := repository.NewPostRepository(client.Database("blogprod"))
-	userRepo := repository.NewUserRepository(client.Database("blogprod"))
-	commentRepo := repository.NewCommentRepository(client.Database("blogprod"))
-
-	// Initialize controllers
-	postController := controller.NewPostController(postRepo)
-	userController := controller.NewUserController(userRepo)
-	commentController := controller.NewCommentController(commentRepo)
-
-	r := chi.NewRouter()
-
-	// Serve files
-	fs := http.FileServer(http.Dir("public"))
-	r.Handle("/public/*", http.StripPrefix("/public/", fs))
-
-	r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Request URL Path: %s", r.URL.Path)
-		path := filepath.Join("public", r.URL.Path)
-	
-		// Check if the file exists and is not a directory
-		if stat, err := os.Stat(path); os.IsNotExist(err) || stat.IsDir() {
-			log.Println("File does not exist or is a directory, serving index.html")
-			http.ServeFile(w, r, "public/index.html")
-		} else {
-			log.Printf("Serving static file: %s", path)
-			http.ServeFile(w, r, path)
-		}
-	})
+		fatal("Failed to ping MongoDB", "error", err)
+	}
+	slog.Info("Connected to MongoDB")
+	return client
+}
 
-	// Apply CORS middleware
-	r.Use(middleware.EnableCORS)
-
-	// Public routes
-	r.Post("/login", userController.Login)
-	r.Post("/register", userController.Register)
-
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware) // Apply auth middleware to all '/api' routes
-
-		r.Get("/posts", postController.GetPosts)
-		r.Post("/posts", postController.CreatePost)
-		r.Get("/posts/user/{userID}", postController.GetPostsByUser)
-		r.Post("/posts", postController.CreatePost)
-		r.Get("/posts/{id}", postController.GetPostByID)
-		r.Put("/posts/{id}", postController.UpdatePost)
-		r.Delete("/posts/{id}", postController.DeletePost)
-
-		r.Get("/profile", userController.GetUserProfile)
-        r.Put("/profile", userController.UpdateUserProfile)
-
-		r.Get("/users", userController.GetUsers)
-		r.Post("/users", userController.CreateUser)
-		r.Get("/users/{id}", userController.GetUser)
-
-		r.Post("/comments", commentController.CreateComment)
-		r.Get("/comments/{id}", commentController.GetCommentsByPost)
-		r.Put("/comments/{id}", commentController.UpdateComment)
-		r.Delete("/comments/{id}", commentController.DeleteComment)
-
-		// Admin-specific routes under '/api/admin'
-		r.Route("/admin", func(r chi.Router) {
-			r.Use(middleware.AdminMiddleware(*repository.NewAdminRepository(client.Database("blog")))) // Apply admin-specific middleware
-			r.Delete("/posts/{id}", postController.AdminDeletePost)
-			r.Delete("/comments/{id}", commentController.AdminDeleteComment)
-		})
-	})
-	
-	r.Get("/dashboard", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "public/index.html")
+// Returns a command monitor that passes every event on to each of monitors
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// Runs the HTTP server until it fails or receives SIGINT or SIGTERM, then shuts down gracefully
+func serve(client *mongo.Client) {
+	// Traces go to the exporter named by OTEL_TRACES_EXPORTER (otlp, stdout or none)
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+		ServiceName: "blog-api",
+		Stdout:      os.Stdout,
 	})
-	
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Handlers expect the current document shapes, so migrations finish before serving
+	if err := migrateOnStart(ctx, client); err != nil {
+		fatal("Failed to run migrations", "error", err)
+	}
+
+	app := newApp(client)
+	go ensureIndexes(ctx, client)
+	go purgeDeletedAccounts(ctx, app.accounts, time.Hour)
+
+	// Routes or models that have drifted from the API documentation are worth fixing but not fatal
+	if problems, err := openapi.Check(app.router, api.Schemas); err != nil {
+		slog.Warn("Failed to check the OpenAPI document", "error", err)
+	} else {
+		for _, problem := range problems {
+			slog.Warn("OpenAPI document out of date", "problem", problem)
+		}
+	}
+
+	// Load balancers need a moment to see /readyz fail before the listener closes
+	shutdownDelay := time.Duration(0)
+	if v := os.Getenv("SHUTDOWN_DELAY"); v != "" {
+		if shutdownDelay, err = time.ParseDuration(v); err != nil {
+			fatal("Invalid SHUTDOWN_DELAY", "error", err)
+		}
+	}
 
 	// Start server
-	log.Println("Starting server on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	server := &http.Server{
+		Addr:     ":8080",
+		Handler:  app.router,
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	}
+	// Event streams never go idle, so they are ended for Shutdown to finish
+	server.RegisterOnShutdown(app.events.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		shutdownTracing(context.Background())
+		fatal("Server stopped", "error", err)
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process immediately
+
+	slog.Info("Shutting down", "delay", shutdownDelay)
+	app.health.ShuttingDown()
+	time.Sleep(shutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to finish in-flight requests", "error", err)
+	}
+	if err := client.Disconnect(shutdownCtx); err != nil {
+		slog.Error("Failed to disconnect from MongoDB", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// The server's router and the parts of the application that outlive single requests
+type app struct {
+	router   *chi.Mux
+	accounts *service.AccountService
+	events   *realtime.Hub
+	health   *health.Checker
+}
+
+// Wires up every repository and builds the router from them. Nothing is sent to the
+// database, so this also works without one.
+func newApp(client *mongo.Client) *app {
+	var err error
+
+	// Hot reads are cached for CACHE_TTL in LRUs of CACHE_SIZE entries each; a TTL of 0 turns caching off
+	cacheTTL := 30 * time.Second
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		if cacheTTL, err = time.ParseDuration(v); err != nil {
+			fatal("Invalid CACHE_TTL", "error", err)
+		}
+	}
+	cacheSize := 1000
+	if v := os.Getenv("CACHE_SIZE"); v != "" {
+		if cacheSize, err = strconv.Atoi(v); err != nil {
+			fatal("Invalid CACHE_SIZE", "error", err)
+		}
+	}
+	postCache := cache.New("posts", cacheSize, cacheTTL)
+
+	// Initialize repositories, wrapped so each call shows up as a span in traces; cache hits skip the span
+	postRepo := repository.NewCachedPostRepository(repository.NewTracedPostRepository(repository.NewPostRepository(client.Database(dbName))), postCache)
+	userRepo := repository.NewCachedUserRepository(repository.NewTracedUserRepository(repository.NewUserRepository(client.Database(dbName))), cache.New("users", cacheSize, cacheTTL))
+	commentRepo := repository.NewTracedCommentRepository(repository.NewCommentRepository(client.Database(dbName)))
+	reactionRepo := repository.NewCachedReactionRepository(repository.NewTracedReactionRepository(repository.NewReactionRepository(client.Database(dbName))), postCache)
+	followRepo := repository.NewTracedFollowRepository(repository.NewFollowRepository(client.Database(dbName)))
+	notificationRepo := repository.NewTracedNotificationRepository(repository.NewNotificationRepository(client.Database(dbName)))
+	mediaRepo := repository.NewTracedMediaRepository(repository.NewMediaRepository(client.Database(dbName)))
+	adminRepo := repository.NewCachedAdminRepository(repository.NewTracedAdminRepository(repository.NewAdminRepository(client.Database(adminDBName))), cache.New("admins", cacheSize, cacheTTL))
+
+	// Initialize media storage
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	mediaStorage, err := storage.NewLocal(mediaDir)
+	if err != nil {
+		fatal("Failed to initialize media storage", "error", err)
+	}
+	mediaMaxBytes := int64(10 << 20) // 10 MB
+	if v := os.Getenv("MEDIA_MAX_BYTES"); v != "" {
+		if mediaMaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			fatal("Invalid MEDIA_MAX_BYTES", "error", err)
+		}
+	}
+
+	// Deleted accounts can be restored for this many days before their content is removed
+	deletionGraceDays := 30
+	if v := os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"); v != "" {
+		if deletionGraceDays, err = strconv.Atoi(v); err != nil {
+			fatal("Invalid ACCOUNT_DELETION_GRACE_DAYS", "error", err)
+		}
+	}
+
+	// Readiness fails while MongoDB can't be reached or indexes are still being built
+	checker := health.NewChecker(2 * time.Second)
+	addReadinessChecks(checker, client)
+
+	// The frontend is embedded in binaries built with -tags embedfrontend and read from public/ otherwise
+	frontend := backend.Frontend
+	if frontend == nil {
+		frontend = os.DirFS("public")
+	}
+	if _, err := fs.Stat(frontend, "index.html"); err != nil {
+		slog.Warn("No frontend build found; only the API is served", "error", err)
+	}
+
+	siteURL := os.Getenv("SITE_URL")
+	if siteURL == "" {
+		siteURL = "http://localhost:8080"
+	}
+	siteTitle := os.Getenv("SITE_TITLE")
+	if siteTitle == "" {
+		siteTitle = "Blog"
+	}
+
+	// Metrics are open to METRICS_TOKEN holders and METRICS_ALLOW addresses, and to no one if neither is set
+	metricsAllow, err := metrics.ParseAllowList(os.Getenv("METRICS_ALLOW"))
+	if err != nil {
+		fatal("Invalid METRICS_ALLOW", "error", err)
+	}
+	metricsAccess := metrics.Access{Token: os.Getenv("METRICS_TOKEN"), Allow: metricsAllow}
+	if !metricsAccess.Configured() {
+		slog.Warn("Neither METRICS_TOKEN nor METRICS_ALLOW is set; /metrics refuses every request")
+	}
+
+	security, err := newSecurityPolicies()
+	if err != nil {
+		fatal("Invalid CSP_REPORT_ONLY", "error", err)
+	}
+
+	built, err := api.New(api.Config{
+		Repositories: api.Repositories{
+			Posts:         postRepo,
+			Users:         userRepo,
+			Comments:      commentRepo,
+			Reactions:     reactionRepo,
+			Follows:       followRepo,
+			Notifications: notificationRepo,
+			Media:         mediaRepo,
+			Admins:        adminRepo,
+		},
+		Storage:             mediaStorage,
+		MediaMaxBytes:       mediaMaxBytes,
+		DeletionGracePeriod: time.Duration(deletionGraceDays) * 24 * time.Hour,
+		Frontend:            frontend,
+		SiteURL:             siteURL,
+		SiteTitle:           siteTitle,
+		Metrics:             metricsAccess,
+		Security:            security,
+		Health:              checker,
+	})
+	if err != nil {
+		fatal("Failed to load the frontend", "error", err)
+	}
+	metrics.RegisterSSEConnections(built.Events.Subscribers)
+
+	return &app{
+		router:   built.Router,
+		accounts: built.Accounts,
+		events:   built.Events,
+		health:   checker,
+	}
+}
+
+// Periodically deletes accounts whose deletion grace period has run out, until ctx is done
+func purgeDeletedAccounts(ctx context.Context, accounts *service.AccountService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purgeCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		purged, err := accounts.PurgeDue(purgeCtx)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to purge deleted accounts", "error", err)
+		} else if purged > 0 {
+			slog.Info("Deleted accounts after their grace period", "count", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/markdown"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+)
+
+// Handles "api import-markdown [-author username] dir". Every .md file under dir is
+// created as a post, or updates the post with the same slug if it has the same author.
+// Files whose slug belongs to another author's post are skipped.
+func runImportMarkdown(client *mongo.Client, args []string) error {
+	flags := flag.NewFlagSet("import-markdown", flag.ExitOnError)
+	defaultAuthor := flags.String("author", "", "username to use for files without an author")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: api import-markdown [-author username] <dir>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	posts := repository.NewPostRepository(client.Database(dbName))
+	users := repository.NewUserRepository(client.Database(dbName))
+	authors := map[string]model.User{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var created, updated, failed int
+	err := filepath.WalkDir(flags.Arg(0), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+
+		isNew, err := importMarkdownFile(ctx, posts, users, authors, path, *defaultAuthor)
+		switch {
+		case err != nil:
+			slog.Warn("Skipped Markdown file", "file", path, "error", err)
+			failed++
+		case isNew:
+			created++
+		default:
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Imported Markdown posts", "created", created, "updated", updated, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be imported", failed)
+	}
+	return nil
+}
+
+func importMarkdownFile(ctx context.Context, posts repository.PostRepository, users repository.UserRepository, authors map[string]model.User, path, defaultAuthor string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	doc, err := markdown.Parse(data)
+	if err != nil {
+		return false, err
+	}
+
+	username := doc.Author
+	if username == "" {
+		username = defaultAuthor
+	}
+	if username == "" {
+		return false, fmt.Errorf("no author in front matter and no -author given")
+	}
+	author, ok := authors[username]
+	if !ok {
+		if author, err = users.GetUserByUsername(ctx, username); err != nil {
+			return false, fmt.Errorf("author %q: %v", username, err)
+		}
+		authors[username] = author
+	}
+
+	post := model.Post{
+		Title:          doc.Title,
+		Slug:           doc.Slug,
+		Content:        doc.Body,
+		Tags:           model.NormalizeTags(doc.Tags),
+		PublishedAt:    doc.Date,
+		AuthorID:       author.ID,
+		AuthorUsername: author.Username,
+	}
+	return posts.UpsertPostBySlug(ctx, &post)
+}
+
+// Handles "api export-markdown [-o dir]", writing every post to <slug>.md
+func runExportMarkdown(client *mongo.Client, args []string) error {
+	flags := flag.NewFlagSet("export-markdown", flag.ExitOnError)
+	output := flags.String("o", "posts", "directory to write the files to")
+	flags.Parse(args)
+
+	if err := os.MkdirAll(*output, 0o755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	posts, err := repository.NewPostRepository(client.Database(dbName)).GetPosts(ctx, bson.M{}, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		data, err := markdown.Render(post)
+		if err != nil {
+			return fmt.Errorf("rendering post %s: %w", post.ID.Hex(), err)
+		}
+		name := post.Slug
+		if name == "" {
+			name = post.ID.Hex()
+		}
+		if err := os.WriteFile(filepath.Join(*output, name+".md"), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Exported posts", "count", len(posts), "dir", *output)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/migrate"
+)
+
+const migrateUsage = `usage: api migrate [command]
+
+Commands:
+  status         List migrations and whether they have been applied (default)
+  up [-to N]     Apply pending migrations, up to version N if given
+  down [-to N]   Revert migrations above version N, or only the latest one
+`
+
+// Applies pending migrations before the server starts, unless MIGRATE_ON_START is false
+// because they are run as a separate deployment step with "api migrate up"
+func migrateOnStart(ctx context.Context, client *mongo.Client) error {
+	if v := os.Getenv("MIGRATE_ON_START"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid MIGRATE_ON_START: %w", err)
+		}
+		if !enabled {
+			return nil
+		}
+	}
+
+	migrator, err := migrate.New(client.Database(dbName), migrate.Migrations())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	applied, err := migrator.Up(ctx, 0)
+	if applied > 0 {
+		slog.Info("Applied migrations", "count", applied, "version", migrator.Latest())
+	}
+	return err
+}
+
+// Handles "api migrate [status | up [-to N] | down [-to N]]"
+func runMigrate(client *mongo.Client, args []string) error {
+	command := "status"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	migrator, err := migrate.New(client.Database(dbName), migrate.Migrations())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	switch command {
+	case "status":
+		return printMigrations(ctx, migrator)
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ExitOnError)
+		to := flags.Int("to", 0, "version to migrate up to (default latest)")
+		flags.Parse(args)
+
+		applied, err := migrator.Up(ctx, *to)
+		fmt.Printf("Applied %d migrations\n", applied)
+		return err
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		to := flags.Int("to", -1, "version to revert to; 0 reverts every migration (default the one before the latest applied)")
+		flags.Parse(args)
+
+		target := *to
+		if target < 0 {
+			if target, err = previousVersion(ctx, migrator); err != nil {
+				return err
+			}
+		}
+		reverted, err := migrator.Down(ctx, target)
+		fmt.Printf("Reverted %d migrations\n", reverted)
+		return err
+	case "help", "-h", "-help", "--help":
+		fmt.Print(migrateUsage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", command, migrateUsage)
+		os.Exit(2)
+		return nil
+	}
+}
+
+// Returns the version below the latest applied migration, so "down" reverts one step
+func previousVersion(ctx context.Context, migrator *migrate.Migrator) (int, error) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var applied []int
+	for _, status := range statuses {
+		if !status.AppliedAt.IsZero() {
+			applied = append(applied, status.Version)
+		}
+	}
+	if len(applied) < 2 {
+		return 0, nil
+	}
+	return applied[len(applied)-2], nil
+}
+
+func printMigrations(ctx context.Context, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if !status.AppliedAt.IsZero() {
+			applied = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		if status.Unknown {
+			applied += " (not in this build)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/openapi"
+)
+
+// Builds the router and reports every difference from the OpenAPI document, failing if
+// there are any. Meant for CI, so it needs neither a .env file nor a running database.
+func runCheckOpenAPI() error {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+	// Connect doesn't dial until the first operation, and building the router performs none
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		return err
+	}
+
+	problems, err := openapi.Check(newApp(client).router, api.Schemas)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("OpenAPI document is out of date: %d problems", len(problems))
+	}
+	fmt.Println("OpenAPI document matches the routes and models")
+	return nil
+}
+
+// Handles "api generate-client [-o file]". The client is committed, and a test fails
+// when it no longer matches the document.
+func runGenerateClient(args []string) error {
+	flags := flag.NewFlagSet("generate-client", flag.ExitOnError)
+	output := flags.String("o", "", "`file` to write instead of stdout, e.g. ../"+openapi.ClientPath)
+	flags.Parse(args)
+
+	client, err := openapi.Client()
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(client)
+		return err
+	}
+	return os.WriteFile(*output, client, 0o644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+)
+
+// Builds the security policies. CSP_CONNECT_SRC lists extra origins the frontend may
+// call, such as a separate VITE_API_URL, and CSP_REPORT_ONLY=true reports violations
+// without blocking them.
+func newSecurityPolicies() (api.SecurityPolicies, error) {
+	reportOnly := false
+	if v := os.Getenv("CSP_REPORT_ONLY"); v != "" {
+		var err error
+		if reportOnly, err = strconv.ParseBool(v); err != nil {
+			return api.SecurityPolicies{}, err
+		}
+	}
+	connectSrc := strings.TrimSpace("'self' " + os.Getenv("CSP_CONNECT_SRC"))
+
+	base := middleware.SecurityPolicy{
+		CSPReportOnly:     reportOnly,
+		ReportURI:         api.CSPReportPath,
+		HSTS:              "max-age=31536000; includeSubDomains",
+		ReferrerPolicy:    "strict-origin-when-cross-origin",
+		PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		FrameOptions:      "DENY",
+	}
+
+	site := base
+	site.CSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+		"img-src 'self' data: blob: https:; font-src 'self'; connect-src " + connectSrc + "; " +
+		"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+
+	jsonAPI := base
+	jsonAPI.CSP = "default-src 'none'; frame-ancestors 'none'"
+	jsonAPI.ReferrerPolicy = "no-referrer"
+
+	docs := base
+	docs.CSP = "default-src 'none'; script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'; " +
+		"connect-src 'self'; img-src 'self' data:; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+	// An uploaded SVG or HTML file opened directly is sandboxed like a foreign origin
+	media := base
+	media.CSP = "default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; sandbox"
+
+	return api.SecurityPolicies{Site: site, API: jsonAPI, Docs: docs, Media: media}, nil
+}
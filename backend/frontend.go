@@ -0,0 +1,8 @@
+// Package backend holds the built frontend when it is embedded into the binary.
+package backend
+
+import "io/fs"
+
+// Frontend holds public/ when the binary is built with -tags embedfrontend, and is nil
+// otherwise, in which case the server reads public/ from the working directory
+var Frontend fs.FS
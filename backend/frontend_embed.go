@@ -0,0 +1,15 @@
+//go:build embedfrontend
+
+package backend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:public
+var public embed.FS
+
+func init() {
+	Frontend, _ = fs.Sub(public, "public")
+}
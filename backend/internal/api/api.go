@@ -0,0 +1,243 @@
+// Package api wires the repositories into services and controllers and builds the
+// server's router. The api command supplies MongoDB-backed repositories; anything
+// implementing the repository interfaces works, which lets tests serve the real routes.
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/controller"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/openapi"
+	"github.com/DavAnders/odin-blogapi/backend/internal/csp"
+	"github.com/DavAnders/odin-blogapi/backend/internal/health"
+	"github.com/DavAnders/odin-blogapi/backend/internal/metrics"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/realtime"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
+	"github.com/DavAnders/odin-blogapi/backend/internal/spa"
+	"github.com/DavAnders/odin-blogapi/backend/internal/storage"
+)
+
+// CSPReportPath is where browsers report Content Security Policy violations
+const CSPReportPath = "/csp-report"
+
+// Schemas maps the OpenAPI document's response schemas to the Go types behind them,
+// for openapi.Check
+var Schemas = map[string]interface{}{
+	"User":                    model.User{},
+	"PublicProfile":           model.PublicProfile{},
+	"UserSummary":             repository.UserProjection{},
+	"Post":                    model.Post{},
+	"PostResponse":            model.PostResponse{},
+	"Comment":                 model.Comment{},
+	"Reaction":                model.Reaction{},
+	"Follow":                  model.Follow{},
+	"Notification":            model.Notification{},
+	"NotificationPreferences": model.NotificationPreferences{},
+	"BuildInfo":               health.BuildInfo{},
+	"HealthStatus":            health.Status{},
+	"Media":                   model.Media{},
+	"MediaVariant":            model.MediaVariant{},
+}
+
+// Repositories are the stores behind every route
+type Repositories struct {
+	Posts         repository.PostRepository
+	Users         repository.UserRepository
+	Comments      repository.CommentRepository
+	Reactions     repository.ReactionRepository
+	Follows       repository.FollowRepository
+	Notifications repository.NotificationRepository
+	Media         repository.MediaRepository
+	Admins        repository.AdminRepository
+}
+
+// SecurityPolicies are the security headers for each group of routes
+type SecurityPolicies struct {
+	Site  middleware.SecurityPolicy // The SPA and other HTML pages
+	API   middleware.SecurityPolicy // JSON responses, which never load anything
+	Docs  middleware.SecurityPolicy // The API documentation page
+	Media middleware.SecurityPolicy // Uploaded files, which must never run script
+}
+
+// Config is everything New needs to build the application
+type Config struct {
+	Repositories
+	Storage             storage.Storage
+	MediaMaxBytes       int64
+	DeletionGracePeriod time.Duration // How long deleted accounts can still be restored
+	Frontend            fs.FS         // The frontend build
+	SiteURL             string
+	SiteTitle           string
+	Metrics             metrics.Access
+	Security            SecurityPolicies
+	Health              *health.Checker
+}
+
+// App is the router and the parts of the application that outlive single requests
+type App struct {
+	Router   *chi.Mux
+	Accounts *service.AccountService
+	Events   *realtime.Hub
+}
+
+// New builds the services, controllers and router. Nothing is sent to the
+// repositories, so the routes can be listed without a database.
+func New(cfg Config) (*App, error) {
+	// Initialize services
+	events := realtime.NewHub()
+	notifier := service.NewNotificationService(cfg.Notifications, cfg.Users, cfg.Posts, cfg.Comments, events)
+	accounts := service.NewAccountService(cfg.Users, cfg.Posts, cfg.Comments, cfg.Reactions, cfg.Follows, cfg.Notifications, cfg.Media, cfg.Storage, cfg.DeletionGracePeriod)
+
+	// Initialize controllers
+	postController := controller.NewPostController(cfg.Posts, cfg.Reactions, cfg.Media, notifier)
+	userController := controller.NewUserController(cfg.Users, cfg.Media)
+	commentController := controller.NewCommentController(cfg.Comments, cfg.Reactions, notifier, events)
+	reactionController := controller.NewReactionController(cfg.Reactions, notifier)
+	followController := controller.NewFollowController(cfg.Follows, cfg.Users, cfg.Posts, cfg.Reactions)
+	notificationController := controller.NewNotificationController(cfg.Notifications, cfg.Users)
+	eventController := controller.NewEventController(events)
+	feedController := controller.NewFeedController(cfg.Posts, cfg.Users, cfg.SiteURL, cfg.SiteTitle)
+	pageController := controller.NewPageController(cfg.Posts, cfg.Frontend, cfg.SiteURL, cfg.SiteTitle)
+	mediaController := controller.NewMediaController(cfg.Media, cfg.Storage, cfg.MediaMaxBytes)
+	accountController := controller.NewAccountController(accounts, cfg.Users)
+
+	spaHandler, err := spa.New(cfg.Frontend)
+	if err != nil {
+		return nil, err
+	}
+
+	r := chi.NewRouter()
+
+	// Tag each request with an ID, trace, log and measure it, then apply CORS and the site's security headers
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Tracing)
+	r.Use(middleware.AccessLog)
+	r.Use(middleware.Metrics)
+	r.Use(middleware.EnableCORS)
+	r.Use(middleware.SecurityHeaders(cfg.Security.Site))
+
+	// Serve files
+	r.Handle("/public/*", http.StripPrefix("/public/", http.FileServer(http.FS(cfg.Frontend))))
+	r.With(middleware.SecurityHeaders(cfg.Security.Media)).Get("/media/*", mediaController.Serve)
+
+	// The SPA: compressed, cached files from the frontend build, and index.html for client-side routes
+	r.Method(http.MethodGet, "/*", spaHandler)
+	// Permalinks redirect old slugs and ObjectIDs to the current slug and render post metadata into the SPA shell
+	r.Method(http.MethodGet, "/posts/{slug}", pageController.PostPage(spaHandler))
+	r.Get("/sitemap.xml", pageController.Sitemap)
+	r.Get("/robots.txt", pageController.Robots)
+
+	// Health and build information for orchestrators
+	r.Get("/healthz", cfg.Health.Live)
+	r.Get("/readyz", cfg.Health.Ready)
+	r.Get("/version", health.Version)
+	r.Method(http.MethodGet, "/metrics", metrics.Handler(cfg.Metrics))
+	r.Post(CSPReportPath, csp.Report)
+
+	// Public routes
+	r.Post("/login", userController.Login)
+	r.Post("/register", userController.Register)
+	r.Post("/logout", userController.Logout)
+
+	// Public feeds
+	r.Get("/feed.rss", feedController.RSS)
+	r.Get("/feed.atom", feedController.Atom)
+	r.Get("/authors/{id}/feed.rss", feedController.AuthorRSS)
+	r.Get("/authors/{id}/feed.atom", feedController.AuthorAtom)
+	r.Get("/tags/{tag}/feed.rss", feedController.TagRSS)
+	r.Get("/tags/{tag}/feed.atom", feedController.TagAtom)
+
+	// API routes
+	r.Route("/api", func(r chi.Router) {
+		// Clients revalidating a GET with If-None-Match get a 304 when the body hasn't changed
+		r.Use(middleware.ETag)
+		r.Use(middleware.SecurityHeaders(cfg.Security.API))
+
+		// API documentation
+		r.Get("/openapi.json", openapi.ServeSpec)
+		r.With(middleware.SecurityHeaders(cfg.Security.Docs)).Get("/docs", openapi.ServeDocs)
+
+		// Read-only routes open to anonymous visitors; a valid token still personalises the response
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.OptionalAuthMiddleware)
+
+			r.Get("/posts", postController.GetPosts)
+			r.Get("/posts/{id}", postController.GetPostByID)
+			r.Get("/posts/by-slug/{slug}", postController.GetPostBySlug)
+			r.Get("/posts/{id}/reactions", reactionController.GetPostReactions)
+
+			r.Get("/comments/{id}", commentController.GetCommentsByPost)
+			r.Get("/comments/{id}/reactions", reactionController.GetCommentReactions)
+
+			r.Get("/users/{id}", userController.GetUser)
+			r.Get("/users/{id}/followers", followController.GetFollowers)
+			r.Get("/users/{id}/following", followController.GetFollowing)
+		})
+
+		// Routes that require a logged-in user
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.AuthMiddleware)
+
+			r.Post("/posts", postController.CreatePost)
+			r.Get("/posts/user/{userID}", postController.GetPostsByUser)
+			r.Put("/posts/{id}", postController.UpdatePost)
+			r.Delete("/posts/{id}", postController.DeletePost)
+			r.Put("/posts/{id}/reactions/{type}", reactionController.AddPostReaction)
+			r.Delete("/posts/{id}/reactions/{type}", reactionController.RemovePostReaction)
+
+			r.Get("/profile", userController.GetUserProfile)
+			r.Put("/profile", userController.UpdateUserProfile)
+			r.Delete("/profile", accountController.RequestDeletion)
+			r.Post("/profile/restore", accountController.CancelDeletion)
+			r.Get("/profile/export", accountController.Export)
+			r.Get("/profile/notifications", notificationController.GetPreferences)
+			r.Put("/profile/notifications", notificationController.UpdatePreferences)
+
+			r.Get("/notifications", notificationController.GetNotifications)
+			r.Get("/notifications/unread-count", notificationController.GetUnreadCount)
+			r.Post("/notifications/read", notificationController.MarkRead)
+
+			r.Get("/events", eventController.Stream)
+
+			r.Get("/users", userController.GetUsers)
+			r.Post("/users", userController.CreateUser)
+			r.Post("/users/{id}/follow", followController.Follow)
+			r.Delete("/users/{id}/follow", followController.Unfollow)
+
+			r.Get("/feed", followController.GetFeed)
+
+			r.Post("/media", mediaController.Upload)
+			r.Get("/media", mediaController.GetMyMedia)
+			r.Get("/media/{id}", mediaController.GetMedia)
+			r.Delete("/media/{id}", mediaController.DeleteMedia)
+
+			r.Post("/comments", commentController.CreateComment)
+			r.Put("/comments/{id}", commentController.UpdateComment)
+			r.Delete("/comments/{id}", commentController.DeleteComment)
+			r.Put("/comments/{id}/reactions/{type}", reactionController.AddCommentReaction)
+			r.Delete("/comments/{id}/reactions/{type}", reactionController.RemoveCommentReaction)
+
+			// Admin-specific routes under '/api/admin'
+			r.Route("/admin", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware(cfg.Admins)) // Apply admin-specific middleware
+				r.Delete("/posts/{id}", postController.AdminDeletePost)
+				r.Delete("/comments/{id}", commentController.AdminDeleteComment)
+			})
+		})
+	})
+
+	r.Method(http.MethodGet, "/dashboard", spaHandler)
+
+	return &App{
+		Router:   r,
+		Accounts: accounts,
+		Events:   events,
+	}, nil
+}
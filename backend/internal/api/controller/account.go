@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
+)
+
+type AccountController struct {
+	accounts *service.AccountService
+	users    repository.UserRepository
+}
+
+func NewAccountController(accounts *service.AccountService, users repository.UserRepository) *AccountController {
+	return &AccountController{
+		accounts: accounts,
+		users:    users,
+	}
+}
+
+// Handles GET requests to download everything stored about the current user as a ZIP archive
+func (c *AccountController) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filename := fmt.Sprintf("export-%s-%s.zip", userID.Hex(), time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// The archive is streamed, so a failure part way through can only be logged
+	if err := c.accounts.Export(r.Context(), userID, w); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to export account", "user_id", userID.Hex(), "error", err)
+	}
+}
+
+// Handles DELETE requests to schedule the current user's account for deletion.
+// The password must be confirmed; the deletion can be cancelled during the grace period.
+func (c *AccountController) RequestDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	username, _ := r.Context().Value(middleware.UsernameKey).(string)
+
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Password == "" {
+		http.Error(w, "Password is required to delete the account", http.StatusBadRequest)
+		return
+	}
+	if _, err := c.users.ValidateCredentials(r.Context(), username, body.Password); err != nil {
+		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	deleteAt, err := c.accounts.RequestDeletion(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to schedule account deletion", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]time.Time{"deleteAt": deleteAt})
+}
+
+// Handles POST requests to cancel a pending deletion of the current user's account
+func (c *AccountController) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := c.accounts.CancelDeletion(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to cancel account deletion", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
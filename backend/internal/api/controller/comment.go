@@ -7,19 +7,28 @@ import (
 	"time"
 
 	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
 	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/realtime"
 	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
 	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type CommentController struct {
-	repo repository.CommentRepository
+	repo      repository.CommentRepository
+	reactions repository.ReactionRepository
+	notifier  *service.NotificationService
+	events    *realtime.Hub
 }
 
-func NewCommentController(repo repository.CommentRepository) *CommentController {
+func NewCommentController(repo repository.CommentRepository, reactions repository.ReactionRepository, notifier *service.NotificationService, events *realtime.Hub) *CommentController {
 	return &CommentController{
-		repo: repo,
+		repo:      repo,
+		reactions: reactions,
+		notifier:  notifier,
+		events:    events,
 	}
 }
 
@@ -30,6 +39,9 @@ func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request
         http.Error(w, "Invalid comment data", http.StatusBadRequest)
         return
     }
+    // Reactions are only counted through the reaction endpoints
+    comment.ReactionCounts = nil
+    comment.MyReactions = nil
 
     // Get the user ID and username from the context
     userID, ok := r.Context().Value(middleware.UserIDKey).(string)
@@ -55,6 +67,15 @@ func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request
         return
     }
 
+    // Replies must stay in the same thread as the comment they answer
+    if comment.ParentID != nil {
+        parent, err := c.repo.GetCommentByID(r.Context(), *comment.ParentID)
+        if err != nil || parent.PostID != postObjID {
+            http.Error(w, "Invalid parent comment", http.StatusBadRequest)
+            return
+        }
+    }
+
     comment.AuthorID = objID
     comment.Author = username
     comment.ID = primitive.NewObjectID()
@@ -66,6 +87,11 @@ func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request
         return
     }
 
+    c.notifier.CommentCreated(r.Context(), comment)
+    if err := c.events.Publish(realtime.PostTopic(comment.PostID.Hex()), "comment.created", comment); err != nil {
+        logging.FromContext(r.Context()).Error("Failed to publish comment event", "comment_id", comment.ID.Hex(), "error", err)
+    }
+
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(comment)
 }
@@ -90,6 +116,10 @@ func (c *CommentController) GetCommentsByPost(w http.ResponseWriter, r *http.Req
         http.Error(w, "Failed to retrieve comments", http.StatusInternalServerError)
         return
     }
+    if err := markMyCommentReactions(r, c.reactions, comments); err != nil {
+        http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+        return
+    }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(comments)
@@ -149,11 +179,15 @@ func (c *CommentController) DeleteComment(w http.ResponseWriter, r *http.Request
         return
     }
 
+    // Look the comment up first so subscribers to its post can be told it is gone
+    existing, _ := c.lookupComment(r, commentID)
+
     // Delete the comment directly with user authorization check in the repo layer
     if err := c.repo.DeleteComment(context.Background(), commentID, &objUserID); err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+    c.publishCommentDeleted(r.Context(), existing)
 
     w.WriteHeader(http.StatusNoContent) // No Content is typical for a successful delete operation
 }
@@ -168,12 +202,34 @@ func (c *CommentController) AdminDeleteComment(w http.ResponseWriter, r *http.Re
         return
     }
 
+    existing, _ := c.lookupComment(r, commentID)
+
     // Pass nil as userID to indicate an admin deletion
     if err := c.repo.DeleteComment(context.Background(), commentID, nil); err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+    c.publishCommentDeleted(r.Context(), existing)
 
     w.WriteHeader(http.StatusNoContent)
 }
 
+func (c *CommentController) lookupComment(r *http.Request, commentID string) (*model.Comment, error) {
+    objID, err := primitive.ObjectIDFromHex(commentID)
+    if err != nil {
+        return nil, err
+    }
+    return c.repo.GetCommentByID(r.Context(), objID)
+}
+
+// Tells subscribers of the comment's post that it was deleted
+func (c *CommentController) publishCommentDeleted(ctx context.Context, comment *model.Comment) {
+    if comment == nil {
+        return
+    }
+    payload := map[string]string{"id": comment.ID.Hex(), "postId": comment.PostID.Hex()}
+    if err := c.events.Publish(realtime.PostTopic(comment.PostID.Hex()), "comment.deleted", payload); err != nil {
+        logging.FromContext(ctx).Error("Failed to publish comment event", "comment_id", comment.ID.Hex(), "error", err)
+    }
+}
+
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/realtime"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often a comment line is sent to keep idle connections and proxies from timing out
+const heartbeatInterval = 25 * time.Second
+
+type EventController struct {
+	hub *realtime.Hub
+}
+
+func NewEventController(hub *realtime.Hub) *EventController {
+	return &EventController{
+		hub: hub,
+	}
+}
+
+// Handles GET requests for a server-sent event stream.
+// The stream always carries the current user's notifications, plus new comments
+// for every post passed as a ?post= query parameter.
+func (c *EventController) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	topics := []string{realtime.UserTopic(userID.Hex())}
+	for _, postID := range r.URL.Query()["post"] {
+		if _, err := primitive.ObjectIDFromHex(postID); err != nil {
+			http.Error(w, "Invalid Post ID", http.StatusBadRequest)
+			return
+		}
+		topics = append(topics, realtime.PostTopic(postID))
+	}
+
+	// Browsers send Last-Event-ID on reconnect; allow a query parameter for clients that cannot set headers
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	resumeFrom, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	sub, missed := c.hub.Subscribe(topics, resumeFrom)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 5000\n\n")
+	for _, event := range missed {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return // Client went away
+		case event, ok := <-sub.C:
+			if !ok {
+				return // Dropped by the hub; the client will reconnect and resume
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event realtime.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/feed"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Number of posts included in each feed
+const feedSize = 20
+
+const (
+	formatRSS  = "rss"
+	formatAtom = "atom"
+)
+
+type FeedController struct {
+	posts     repository.PostRepository
+	users     repository.UserRepository
+	siteURL   string
+	siteTitle string
+}
+
+func NewFeedController(posts repository.PostRepository, users repository.UserRepository, siteURL, siteTitle string) *FeedController {
+	return &FeedController{
+		posts:     posts,
+		users:     users,
+		siteURL:   strings.TrimSuffix(siteURL, "/"),
+		siteTitle: siteTitle,
+	}
+}
+
+// Handles GET requests for the site-wide RSS feed
+func (c *FeedController) RSS(w http.ResponseWriter, r *http.Request) {
+	c.siteFeed(w, r, formatRSS)
+}
+
+// Handles GET requests for the site-wide Atom feed
+func (c *FeedController) Atom(w http.ResponseWriter, r *http.Request) {
+	c.siteFeed(w, r, formatAtom)
+}
+
+// Handles GET requests for an author's RSS feed
+func (c *FeedController) AuthorRSS(w http.ResponseWriter, r *http.Request) {
+	c.authorFeed(w, r, formatRSS)
+}
+
+// Handles GET requests for an author's Atom feed
+func (c *FeedController) AuthorAtom(w http.ResponseWriter, r *http.Request) {
+	c.authorFeed(w, r, formatAtom)
+}
+
+// Handles GET requests for a tag's RSS feed
+func (c *FeedController) TagRSS(w http.ResponseWriter, r *http.Request) {
+	c.tagFeed(w, r, formatRSS)
+}
+
+// Handles GET requests for a tag's Atom feed
+func (c *FeedController) TagAtom(w http.ResponseWriter, r *http.Request) {
+	c.tagFeed(w, r, formatAtom)
+}
+
+func (c *FeedController) siteFeed(w http.ResponseWriter, r *http.Request, format string) {
+	channel := feed.Channel{
+		Title:       c.siteTitle,
+		Description: "Latest posts from " + c.siteTitle,
+		SiteURL:     c.siteURL,
+		FeedURL:     c.siteURL + r.URL.Path,
+	}
+	c.serveFeed(w, r, format, channel, bson.M{})
+}
+
+func (c *FeedController) authorFeed(w http.ResponseWriter, r *http.Request, format string) {
+	author, err := c.users.GetUser(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	channel := feed.Channel{
+		Title:       fmt.Sprintf("%s - posts by %s", c.siteTitle, author.Username),
+		Description: "Latest posts by " + author.Username,
+		SiteURL:     c.siteURL,
+		FeedURL:     c.siteURL + r.URL.Path,
+	}
+	c.serveFeed(w, r, format, channel, bson.M{"authorId": author.ID})
+}
+
+func (c *FeedController) tagFeed(w http.ResponseWriter, r *http.Request, format string) {
+	tag := model.NormalizeTag(chi.URLParam(r, "tag"))
+	if tag == "" {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	channel := feed.Channel{
+		Title:       fmt.Sprintf("%s - %s", c.siteTitle, tag),
+		Description: "Latest posts tagged " + tag,
+		SiteURL:     c.siteURL,
+		FeedURL:     c.siteURL + r.URL.Path,
+	}
+	c.serveFeed(w, r, format, channel, bson.M{"tags": tag})
+}
+
+// Renders the newest posts matching filter and serves them with an ETag so feed
+// readers polling with If-None-Match get a 304. There is no Last-Modified: edits don't
+// change the newest publish time and deletions move it backwards, so readers relying
+// on If-Modified-Since would miss changes.
+func (c *FeedController) serveFeed(w http.ResponseWriter, r *http.Request, format string, channel feed.Channel, filter bson.M) {
+	posts, err := c.posts.GetPosts(r.Context(), filter, feedSize, 0)
+	if err != nil {
+		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+	channel.Updated = lastPublished(posts)
+
+	var body []byte
+	var contentType string
+	switch format {
+	case formatAtom:
+		body, err = feed.Atom(channel, posts)
+		contentType = "application/atom+xml; charset=utf-8"
+	default:
+		body, err = feed.RSS(channel, posts)
+		contentType = "application/rss+xml; charset=utf-8"
+	}
+	if err != nil {
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(body)))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// Returns the newest publish time among posts
+func lastPublished(posts []model.Post) time.Time {
+	var latest time.Time
+	for _, post := range posts {
+		if post.PublishedAt.After(latest) {
+			latest = post.PublishedAt
+		}
+	}
+	return latest
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FollowController struct {
+	repo      repository.FollowRepository
+	users     repository.UserRepository
+	posts     repository.PostRepository
+	reactions repository.ReactionRepository
+}
+
+func NewFollowController(repo repository.FollowRepository, users repository.UserRepository, posts repository.PostRepository, reactions repository.ReactionRepository) *FollowController {
+	return &FollowController{
+		repo:      repo,
+		users:     users,
+		posts:     posts,
+		reactions: reactions,
+	}
+}
+
+// Handles POST requests to follow an author
+func (c *FollowController) Follow(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	username, _ := r.Context().Value(middleware.UsernameKey).(string)
+
+	followee, err := c.users.GetUser(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if followee.ID == followerID {
+		http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	follow := model.Follow{
+		FollowerID:       followerID,
+		FollowerUsername: username,
+		FolloweeID:       followee.ID,
+		FolloweeUsername: followee.Username,
+	}
+	created, err := c.repo.Follow(r.Context(), follow)
+	if err != nil {
+		http.Error(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK // Already following
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"followeeId": followee.ID.Hex()})
+}
+
+// Handles DELETE requests to unfollow an author
+func (c *FollowController) Unfollow(w http.ResponseWriter, r *http.Request) {
+	followerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	followeeID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := c.repo.Unfollow(r.Context(), followerID, followeeID); err != nil {
+		http.Error(w, "Failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles GET requests to list a user's followers
+func (c *FollowController) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, skip := pageParams(r, 50)
+	followers, err := c.repo.GetFollowers(r.Context(), userID, limit, skip)
+	if err != nil {
+		http.Error(w, "Failed to retrieve followers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(followers)
+}
+
+// Handles GET requests to list the authors a user follows
+func (c *FollowController) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	limit, skip := pageParams(r, 50)
+	following, err := c.repo.GetFollowing(r.Context(), userID, limit, skip)
+	if err != nil {
+		http.Error(w, "Failed to retrieve followed users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(following)
+}
+
+// Handles GET requests for the current user's feed of posts from followed authors.
+// Paging and ordering are the same as the global timeline served by GetPosts.
+func (c *FollowController) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	authorIDs, err := c.repo.GetFollowingIDs(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve followed users", http.StatusInternalServerError)
+		return
+	}
+
+	posts := []model.Post{}
+	if len(authorIDs) > 0 {
+		limit, skip := pageParams(r, 10)
+		posts, err = c.posts.GetPosts(r.Context(), bson.M{"authorId": bson.M{"$in": authorIDs}}, limit, skip)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if posts == nil {
+			posts = []model.Post{} // Followed authors without posts still give an empty list
+		}
+		if err := markMyPostReactions(r, c.reactions, posts); err != nil {
+			http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts)
+}
@@ -3,7 +3,10 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/metrics"
 	"github.com/DavAnders/odin-blogapi/backend/pkg/jwt"
 )
 
@@ -18,6 +21,7 @@ func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
     }
 
     user, err := c.repo.ValidateCredentials(r.Context(), credentials.Username, credentials.Password)
+    metrics.ObserveLogin(err == nil)
     if err != nil {
         http.Error(w, "Invalid credentials", http.StatusUnauthorized)
         return
@@ -29,18 +33,20 @@ func (c *UserController) Login(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{"token": token})
+    writeSession(w, token)
+}
+
+// Handles POST requests to log out by clearing the session cookies. Bearer tokens
+// can't be revoked; clients using them just discard theirs.
+func (c *UserController) Logout(w http.ResponseWriter, r *http.Request) {
+    middleware.ClearSessionCookies(w)
+    w.WriteHeader(http.StatusNoContent)
 }
 
-// func setTokenAsCookie(w http.ResponseWriter, tokenString string) {
-//     http.SetCookie(w, &http.Cookie{
-//         Name:     "token",
-//         Value:    tokenString,
-//         Expires:  time.Now().Add(1 * time.Hour),
-//         HttpOnly: true, // JavaScript can't access cookie
-//         Path:     "/",  // Cookie available on all paths
-//         Secure:   false, // For development purposes
-//         SameSite: http.SameSiteStrictMode,
-//     })
-// }
+// Sets the session cookies for token and returns it, with its CSRF token, in the body
+// for clients that send it as a Bearer token instead
+func writeSession(w http.ResponseWriter, token string) {
+    csrf := middleware.SetSessionCookies(w, token, time.Now().Add(jwt.TokenLifetime))
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]string{"token": token, "csrfToken": csrf})
+}
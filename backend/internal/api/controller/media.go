@@ -0,0 +1,319 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/imaging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Content types accepted for upload, detected from the file contents rather than trusted from the client,
+// mapped to the extension used for the stored file
+var allowedMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type MediaController struct {
+	repo     repository.MediaRepository
+	storage  storage.Storage
+	maxBytes int64
+}
+
+func NewMediaController(repo repository.MediaRepository, storage storage.Storage, maxBytes int64) *MediaController {
+	return &MediaController{
+		repo:     repo,
+		storage:  storage,
+		maxBytes: maxBytes,
+	}
+}
+
+// Handles multipart POST requests uploading a file in the "file" field
+func (c *MediaController) Upload(w http.ResponseWriter, r *http.Request) {
+	ownerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Leave some room for the multipart framing around the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, c.maxBytes+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("File is too large (max %d bytes)", c.maxBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "A file is required in the 'file' field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > c.maxBytes {
+		http.Error(w, fmt.Sprintf("File is too large (max %d bytes)", c.maxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedMediaTypes[contentType]; !ok {
+		http.Error(w, "Unsupported file type: "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Profile pictures also get square crops
+	specs := append([]imaging.Spec{}, imaging.Responsive...)
+	specs = append(specs, imaging.Thumbnail)
+	if r.FormValue("purpose") == "avatar" {
+		specs = append(specs, imaging.Avatar...)
+	}
+
+	original, variants, err := imaging.Process(data, contentType, specs)
+	if err == imaging.ErrUnsupported {
+		// Stored as uploaded, e.g. animated GIFs
+		original = imaging.Image{Name: "original", Data: data, ContentType: contentType, Ext: allowedMediaTypes[contentType]}
+		if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			original.Width, original.Height = config.Width, config.Height
+		}
+	} else if err == imaging.ErrTooLarge {
+		http.Error(w, fmt.Sprintf("Image is too large (max %d pixels)", imaging.MaxPixels), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to process image", http.StatusUnprocessableEntity)
+		return
+	}
+
+	media := model.Media{
+		ID:          primitive.NewObjectID(),
+		OwnerID:     ownerID,
+		Filename:    path.Base(header.Filename),
+		ContentType: original.ContentType,
+		Size:        int64(len(original.Data)),
+		Width:       original.Width,
+		Height:      original.Height,
+		CreatedAt:   time.Now(),
+	}
+
+	// Files are named by content hash so they can be cached forever
+	var stored []string
+	cleanup := func() {
+		c.deleteUnusedFiles(r, stored)
+	}
+	store := func(img imaging.Image) (string, error) {
+		key := hashedKey(img.Data, img.Ext)
+		if err := c.storage.Save(r.Context(), key, bytes.NewReader(img.Data)); err != nil {
+			return "", err
+		}
+		stored = append(stored, key)
+		return key, nil
+	}
+
+	if media.Key, err = store(original); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to store upload", "error", err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	media.URL = "/media/" + media.Key
+	for _, variant := range variants {
+		key, err := store(variant)
+		if err != nil {
+			cleanup()
+			logging.FromContext(r.Context()).Error("Failed to store image variant", "variant", variant.Name, "error", err)
+			http.Error(w, "Failed to store file", http.StatusInternalServerError)
+			return
+		}
+		media.Variants = append(media.Variants, model.MediaVariant{
+			Name:   variant.Name,
+			Key:    key,
+			URL:    "/media/" + key,
+			Width:  variant.Width,
+			Height: variant.Height,
+			Size:   int64(len(variant.Data)),
+		})
+	}
+
+	if err := c.repo.CreateMedia(r.Context(), &media); err != nil {
+		cleanup() // Don't leave orphaned files behind
+		http.Error(w, "Failed to save media", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(media)
+}
+
+// Handles GET requests to list the current user's uploads
+func (c *MediaController) GetMyMedia(w http.ResponseWriter, r *http.Request) {
+	ownerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, skip := pageParams(r, 50)
+	media, err := c.repo.GetMediaByOwner(r.Context(), ownerID, limit, skip)
+	if err != nil {
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+// Handles GET requests for the metadata of one of the current user's uploads. Other
+// users' uploads are reported as not found, so their IDs can't be probed.
+func (c *MediaController) GetMedia(w http.ResponseWriter, r *http.Request) {
+	ownerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := c.repo.GetMedia(r.Context(), id)
+	if err == mongo.ErrNoDocuments || (err == nil && media.OwnerID != ownerID) {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+// Handles DELETE requests to remove one of the current user's uploads; other users'
+// uploads are reported as not found
+func (c *MediaController) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	ownerID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := c.repo.GetMedia(r.Context(), id)
+	if err == mongo.ErrNoDocuments || (err == nil && media.OwnerID != ownerID) {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.repo.DeleteMedia(r.Context(), id, &ownerID); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to delete media", "media_id", id.Hex(), "error", err)
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+	keys := []string{media.Key}
+	for _, variant := range media.Variants {
+		keys = append(keys, variant.Key)
+	}
+	c.deleteUnusedFiles(r, keys)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handles GET requests under /media/ by streaming the stored file
+func (c *MediaController) Serve(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	object, err := c.storage.Open(r.Context(), key)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			logging.FromContext(r.Context()).Error("Failed to open stored file", "key", key, "error", err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if hashedKeyPattern.MatchString(key) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable") // Content never changes under this name
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	}
+	http.ServeContent(w, r, path.Base(key), object.ModTime(), object)
+}
+
+// Loads an image uploaded by ownerID so it can be linked from a profile or post
+func ownedImage(r *http.Request, repo repository.MediaRepository, id primitive.ObjectID, ownerID primitive.ObjectID) (*model.Media, error) {
+	media, err := repo.GetMedia(r.Context(), id)
+	if err != nil || media.OwnerID != ownerID {
+		return nil, fmt.Errorf("media not found")
+	}
+	if !media.IsImage() {
+		return nil, fmt.Errorf("media is not an image")
+	}
+	return media, nil
+}
+
+// Deletes stored files that no media document references any more
+func (c *MediaController) deleteUnusedFiles(r *http.Request, keys []string) {
+	for _, key := range keys {
+		inUse, err := c.repo.KeyInUse(r.Context(), key)
+		if err != nil || inUse {
+			continue // Keeping a file is safer than breaking another upload
+		}
+		if err := c.storage.Delete(r.Context(), key); err != nil {
+			logging.FromContext(r.Context()).Error("Failed to delete stored file", "key", key, "error", err)
+		}
+	}
+}
+
+// Matches the content-hash keys produced by hashedKey
+var hashedKeyPattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{32}\.[a-z]+$`)
+
+// Returns a storage key derived from the file's content, e.g. "3f/3fa2...e1.jpg"
+func hashedKey(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:16])
+	return hash[:2] + "/" + hash + ext
+}
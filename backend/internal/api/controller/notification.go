@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationController struct {
+	repo  repository.NotificationRepository
+	users repository.UserRepository
+}
+
+func NewNotificationController(repo repository.NotificationRepository, users repository.UserRepository) *NotificationController {
+	return &NotificationController{
+		repo:  repo,
+		users: users,
+	}
+}
+
+// Handles GET requests to list the current user's notifications
+func (c *NotificationController) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, skip := pageParams(r, 20)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := c.repo.GetNotifications(r.Context(), userID, unreadOnly, limit, skip)
+	if err != nil {
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// Handles GET requests for the current user's unread notification count
+func (c *NotificationController) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := c.repo.CountUnread(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"unread": count})
+}
+
+// Handles POST requests to mark notifications as read.
+// An empty or missing list of IDs marks every notification as read.
+func (c *NotificationController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		IDs []primitive.ObjectID `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updated, err := c.repo.MarkRead(r.Context(), userID, body.IDs)
+	if err != nil {
+		http.Error(w, "Failed to mark notifications as read", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
+// Handles GET requests for the current user's notification preferences
+func (c *NotificationController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := c.users.GetUser(r.Context(), userID.Hex())
+	if err != nil {
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.NotificationPreferences())
+}
+
+// Handles PUT requests to replace the current user's notification preferences
+func (c *NotificationController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs := model.DefaultNotificationPreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := c.users.UpdateNotificationPreferences(r.Context(), userID, prefs); err != nil {
+		http.Error(w, "Failed to update notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
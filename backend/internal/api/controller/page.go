@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/csp"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/seo"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upper bound on the number of posts listed in sitemap.xml, the limit of a single sitemap file
+const sitemapSize = 50000
+
+// PageController serves the HTML pages and files that crawlers and link previews read
+type PageController struct {
+	posts     repository.PostRepository
+	shell     fs.FS // Built frontend, containing index.html
+	siteURL   string
+	siteTitle string
+}
+
+func NewPageController(posts repository.PostRepository, shell fs.FS, siteURL, siteTitle string) *PageController {
+	return &PageController{
+		posts:     posts,
+		shell:     shell,
+		siteURL:   strings.TrimSuffix(siteURL, "/"),
+		siteTitle: siteTitle,
+	}
+}
+
+// PostPage wraps the SPA handler for /posts/{slug}. Links using a post's ObjectID
+// or an old slug are permanently redirected to the current slug, and known posts get
+// the SPA shell with their title, description, Open Graph and JSON-LD metadata filled in.
+// Anything else falls through to next.
+func (c *PageController) PostPage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested := chi.URLParam(r, "slug")
+
+		var post *model.Post
+		var err error
+		if primitive.IsValidObjectID(requested) {
+			post, err = c.posts.GetPostByID(r.Context(), requested)
+		} else {
+			post, err = c.posts.GetPostBySlug(r.Context(), requested)
+		}
+		if err != nil {
+			next.ServeHTTP(w, r) // Let the SPA show its own not found page
+			return
+		}
+
+		if post.Slug != "" && post.Slug != requested {
+			target := "/posts/" + url.PathEscape(post.Slug)
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		shell, err := fs.ReadFile(c.shell, "index.html")
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		page, err := seo.Inject(shell, seo.PostMeta(*post, c.siteURL, c.siteTitle))
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to render post page", "post_id", post.ID.Hex(), "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(csp.Inject(page, csp.Nonce(r.Context())))
+	})
+}
+
+// Handles GET requests for sitemap.xml
+func (c *PageController) Sitemap(w http.ResponseWriter, r *http.Request) {
+	posts, err := c.posts.GetPosts(r.Context(), bson.M{}, sitemapSize, 0)
+	if err != nil {
+		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := seo.Sitemap(c.siteURL, posts)
+	if err != nil {
+		http.Error(w, "Failed to render sitemap", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(body)
+}
+
+// Handles GET requests for robots.txt
+func (c *PageController) Robots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(seo.Robots(c.siteURL))
+}
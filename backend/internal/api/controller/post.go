@@ -4,25 +4,34 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
 	"github.com/DavAnders/odin-blogapi/backend/internal/model"
 	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
 	"github.com/go-chi/chi/v5"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type PostController struct {
-	repo repository.PostRepository
+	repo      repository.PostRepository
+	reactions repository.ReactionRepository
+	media     repository.MediaRepository
+	notifier  *service.NotificationService
 }
 
-func NewPostController(repo repository.PostRepository) *PostController {
+func NewPostController(repo repository.PostRepository, reactions repository.ReactionRepository, media repository.MediaRepository, notifier *service.NotificationService) *PostController {
 	return &PostController{
-		repo: repo,
+		repo:      repo,
+		reactions: reactions,
+		media:     media,
+		notifier:  notifier,
 	}
 }
 
@@ -35,6 +44,12 @@ func (c *PostController) CreatePost(w http.ResponseWriter, r *http.Request) {
     }
 
     post.PublishedAt = time.Now()
+    post.Tags = model.NormalizeTags(post.Tags)
+    // Reactions are only counted through the reaction endpoints
+    post.ReactionCounts = nil
+    post.MyReactions = nil
+    // Slugs come from the title; only imports may carry their own
+    post.Slug = ""
 
     userID, ok := r.Context().Value(middleware.UserIDKey).(string)
     if !ok {
@@ -56,20 +71,33 @@ func (c *PostController) CreatePost(w http.ResponseWriter, r *http.Request) {
     }
     post.AuthorUsername = username
 
+    if err := c.setCoverImage(r, &post, objID); err != nil {
+        http.Error(w, "Invalid cover image: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+
     if err := c.repo.CreatePost(r.Context(), &post); err != nil {
         http.Error(w, "Failed to create post: "+err.Error(), http.StatusInternalServerError)
         return
     }
 
+    c.notifier.PostCreated(r.Context(), post)
+
     post.AuthorUsername = username
     
     response := model.PostResponse{
         ID:             post.ID.Hex(),
         Title:          post.Title,
+        Slug:           post.Slug,
         Content:        post.Content,
         PublishedAt:    post.PublishedAt,
         AuthorID:       post.AuthorID.Hex(), 
         AuthorUsername: post.AuthorUsername,
+        Tags:           post.Tags,
+        CoverImage:     post.CoverImage,
+    }
+    if post.CoverMediaID != nil {
+        response.CoverMediaID = post.CoverMediaID.Hex()
     }
 
     w.Header().Set("Content-Type", "application/json")
@@ -94,13 +122,18 @@ func (c *PostController) GetPosts(w http.ResponseWriter, r *http.Request) {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+    if err := markMyPostReactions(r, c.reactions, posts); err != nil {
+        http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+        return
+    }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(posts)
 }
 
 
-// Handles GET requests to retrieve a post by ID
+// Handles GET requests to retrieve a post by ID.
+// Anything that is not an ObjectID is treated as a slug so SPA permalinks can be fetched directly.
 func (c *PostController) GetPostByID(w http.ResponseWriter, r *http.Request) {
     postID := chi.URLParam(r, "id")
     if postID == "" {
@@ -108,14 +141,74 @@ func (c *PostController) GetPostByID(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    post, err := c.repo.GetPostByID(context.Background(), postID)
+    var post *model.Post
+    var err error
+    if primitive.IsValidObjectID(postID) {
+        post, err = c.repo.GetPostByID(context.Background(), postID)
+    } else {
+        post, err = c.repo.GetPostBySlug(r.Context(), postID)
+    }
+    if err == mongo.ErrNoDocuments {
+        http.Error(w, "Post not found", http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, "Failed to retrieve post", http.StatusInternalServerError)
+        return
+    }
+
+    c.writePost(w, r, post)
+}
+
+// Handles GET requests to retrieve a post by slug. Old slugs redirect to the current one.
+func (c *PostController) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+    postSlug := chi.URLParam(r, "slug")
+    if postSlug == "" {
+        http.Error(w, "Slug is required", http.StatusBadRequest)
+        return
+    }
+
+    post, err := c.repo.GetPostBySlug(r.Context(), postSlug)
+    if err == mongo.ErrNoDocuments {
+        http.Error(w, "Post not found", http.StatusNotFound)
+        return
+    }
     if err != nil {
         http.Error(w, "Failed to retrieve post", http.StatusInternalServerError)
         return
     }
 
+    if post.Slug != postSlug {
+        http.Redirect(w, r, "/api/posts/by-slug/"+url.PathEscape(post.Slug), http.StatusMovedPermanently)
+        return
+    }
+
+    c.writePost(w, r, post)
+}
+
+// Points the post's cover image at the requested upload, or clears it when none was given
+func (c *PostController) setCoverImage(r *http.Request, post *model.Post, ownerID primitive.ObjectID) error {
+    post.CoverImage = ""
+    if post.CoverMediaID == nil {
+        return nil
+    }
+    media, err := ownedImage(r, c.media, *post.CoverMediaID, ownerID)
+    if err != nil {
+        return err
+    }
+    post.CoverImage = media.VariantURL("w1280", "w640")
+    return nil
+}
+
+func (c *PostController) writePost(w http.ResponseWriter, r *http.Request, post *model.Post) {
+    posts := []model.Post{*post}
+    if err := markMyPostReactions(r, c.reactions, posts); err != nil {
+        http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+        return
+    }
+
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(post)
+    json.NewEncoder(w).Encode(posts[0])
 }
 
 // Handles GET requests to retrieve all posts by a user
@@ -143,6 +236,10 @@ func (c *PostController) GetPostsByUser(w http.ResponseWriter, r *http.Request)
         http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
         return
     }
+    if err := markMyPostReactions(r, c.reactions, posts); err != nil {
+        http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+        return
+    }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(posts)
@@ -171,6 +268,17 @@ func (c *PostController) UpdatePost(w http.ResponseWriter, r *http.Request) {
     }
 
     updatedPost.ID = objID
+    updatedPost.Tags = model.NormalizeTags(updatedPost.Tags)
+
+    userID, ok := currentUserID(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+    if err := c.setCoverImage(r, &updatedPost, userID); err != nil {
+        http.Error(w, "Invalid cover image: "+err.Error(), http.StatusBadRequest)
+        return
+    }
 
     if err := c.repo.UpdatePost(context.Background(), updatedPost); err != nil {
         http.Error(w, "Failed to update post", http.StatusInternalServerError)
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReactionController struct {
+	repo     repository.ReactionRepository
+	notifier *service.NotificationService
+}
+
+func NewReactionController(repo repository.ReactionRepository, notifier *service.NotificationService) *ReactionController {
+	return &ReactionController{
+		repo:     repo,
+		notifier: notifier,
+	}
+}
+
+// Handles PUT requests to react to a post
+func (c *ReactionController) AddPostReaction(w http.ResponseWriter, r *http.Request) {
+	c.addReaction(w, r, model.ReactionTargetPost)
+}
+
+// Handles DELETE requests to remove a reaction from a post
+func (c *ReactionController) RemovePostReaction(w http.ResponseWriter, r *http.Request) {
+	c.removeReaction(w, r, model.ReactionTargetPost)
+}
+
+// Handles GET requests to list who reacted to a post
+func (c *ReactionController) GetPostReactions(w http.ResponseWriter, r *http.Request) {
+	c.getReactions(w, r, model.ReactionTargetPost)
+}
+
+// Handles PUT requests to react to a comment
+func (c *ReactionController) AddCommentReaction(w http.ResponseWriter, r *http.Request) {
+	c.addReaction(w, r, model.ReactionTargetComment)
+}
+
+// Handles DELETE requests to remove a reaction from a comment
+func (c *ReactionController) RemoveCommentReaction(w http.ResponseWriter, r *http.Request) {
+	c.removeReaction(w, r, model.ReactionTargetComment)
+}
+
+// Handles GET requests to list who reacted to a comment
+func (c *ReactionController) GetCommentReactions(w http.ResponseWriter, r *http.Request) {
+	c.getReactions(w, r, model.ReactionTargetComment)
+}
+
+func (c *ReactionController) addReaction(w http.ResponseWriter, r *http.Request, targetType string) {
+	targetID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	reactionType := chi.URLParam(r, "type")
+	if !model.IsValidReactionType(reactionType) {
+		http.Error(w, "Invalid reaction type", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	username, _ := r.Context().Value(middleware.UsernameKey).(string)
+
+	reaction := model.Reaction{
+		TargetType: targetType,
+		TargetID:   targetID,
+		UserID:     userID,
+		Username:   username,
+		Type:       reactionType,
+	}
+	created, err := c.repo.AddReaction(r.Context(), reaction)
+	if errors.Is(err, repository.ErrReactionTargetNotFound) {
+		http.Error(w, "Target not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to add reaction", "target_type", targetType, "target_id", targetID.Hex(), "error", err)
+		http.Error(w, "Failed to add reaction", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK // Reaction already existed
+	if created {
+		status = http.StatusCreated
+		c.notifier.ReactionAdded(r.Context(), reaction)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"type": reactionType})
+}
+
+func (c *ReactionController) removeReaction(w http.ResponseWriter, r *http.Request, targetType string) {
+	targetID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	reactionType := chi.URLParam(r, "type")
+	if !model.IsValidReactionType(reactionType) {
+		http.Error(w, "Invalid reaction type", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := currentUserID(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Removing a reaction that isn't there, or from a missing target, is not an error
+	if _, err := c.repo.RemoveReaction(r.Context(), targetType, targetID, userID, reactionType); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to remove reaction", "target_type", targetType, "target_id", targetID.Hex(), "error", err)
+		http.Error(w, "Failed to remove reaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *ReactionController) getReactions(w http.ResponseWriter, r *http.Request, targetType string) {
+	targetID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	reactionType := r.URL.Query().Get("type")
+	if reactionType != "" && !model.IsValidReactionType(reactionType) {
+		http.Error(w, "Invalid reaction type", http.StatusBadRequest)
+		return
+	}
+
+	limit, skip := pageParams(r, 50)
+
+	reactions, err := c.repo.GetReactions(r.Context(), targetType, targetID, reactionType, limit, skip)
+	if err != nil {
+		http.Error(w, "Failed to retrieve reactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
+// Fills MyReactions on each post with the reaction types the current user left
+func markMyPostReactions(r *http.Request, repo repository.ReactionRepository, posts []model.Post) error {
+	userID, ok := currentUserID(r)
+	if !ok || len(posts) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	mine, err := repo.GetUserReactions(r.Context(), model.ReactionTargetPost, ids, userID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].MyReactions = mine[posts[i].ID]
+	}
+	return nil
+}
+
+// Fills MyReactions on each comment with the reaction types the current user left
+func markMyCommentReactions(r *http.Request, repo repository.ReactionRepository, comments []model.Comment) error {
+	userID, ok := currentUserID(r)
+	if !ok || len(comments) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	mine, err := repo.GetUserReactions(r.Context(), model.ReactionTargetComment, ids, userID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].MyReactions = mine[comments[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Returns the authenticated user's ID from the request context
+func currentUserID(r *http.Request) (primitive.ObjectID, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok || userID == "" {
+		return primitive.NilObjectID, false
+	}
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return objID, true
+}
+
+// Reads the limit and skip query parameters, falling back to defaultLimit and 0
+func pageParams(r *http.Request, defaultLimit int64) (int64, int64) {
+	limit := defaultLimit
+	skip := int64(0)
+	if limitQuery := r.URL.Query().Get("limit"); limitQuery != "" {
+		limit, _ = strconv.ParseInt(limitQuery, 10, 64)
+	}
+	if skipQuery := r.URL.Query().Get("skip"); skipQuery != "" {
+		skip, _ = strconv.ParseInt(skipQuery, 10, 64)
+	}
+	return limit, skip
+}
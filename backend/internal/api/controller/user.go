@@ -3,25 +3,29 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
 	"github.com/DavAnders/odin-blogapi/backend/internal/model"
 	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
 	"github.com/DavAnders/odin-blogapi/backend/pkg/jwt"
 	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type UserController struct {
-	repo repository.UserRepository
+	repo  repository.UserRepository
+	media repository.MediaRepository
 }
 
-func NewUserController(repo repository.UserRepository) *UserController {
+func NewUserController(repo repository.UserRepository, media repository.MediaRepository) *UserController {
 	return &UserController{
-		repo: repo,
+		repo:  repo,
+		media: media,
 	}
 }
 
@@ -36,17 +40,18 @@ func (c *UserController) Register(w http.ResponseWriter, r *http.Request) {
     // Check if the username already exists
     _, err := c.repo.GetUserByUsername(r.Context(), user.Username)
     if err == nil {
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusConflict)
-        json.NewEncoder(w).Encode(map[string]string{"error": "Username already exists"})
+        writeAccountTaken(w, repository.ErrUsernameTaken)
         return
     } else if err.Error() != "user not found" {
         http.Error(w, "Failed to check user existence", http.StatusInternalServerError)
         return
     }
 
-    // Create the new user
+    // Create the new user. A registration racing this one is caught by the unique index.
     err = c.repo.CreateUser(r.Context(), user)
+    if writeAccountTaken(w, err) {
+        return
+    }
     if err != nil {
         http.Error(w, "Failed to create user", http.StatusInternalServerError)
         return
@@ -66,22 +71,26 @@ func (c *UserController) Register(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Set the token as a cookie
-    http.SetCookie(w, &http.Cookie{
-        Name:     "token",
-        Value:    token,
-        Expires:  time.Now().Add(24 * time.Hour),
-        HttpOnly: true,
-        Secure:   true,
-        Path:     "/",
-        SameSite: http.SameSiteStrictMode,
-    })
+    // Log the new user in, in a cookie and in the response body
+    writeSession(w, token)
+}
 
-    // Return the token in the response body as well
+// Answers a registration that collides with an existing account with 409, naming the
+// field that collided. Reports whether err was such a collision.
+func writeAccountTaken(w http.ResponseWriter, err error) bool {
+    var message string
+    switch {
+    case errors.Is(err, repository.ErrEmailTaken):
+        message = "Email already registered"
+    case errors.Is(err, repository.ErrUsernameTaken):
+        message = "Username already exists"
+    default:
+        return false
+    }
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{
-        "token": token,
-    })
+    w.WriteHeader(http.StatusConflict)
+    json.NewEncoder(w).Encode(map[string]string{"error": message})
+    return true
 }
 
 // Handles POST requests to create a new user
@@ -92,8 +101,10 @@ func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    if err := c.repo.CreateUser(context.Background(), user); err != nil {
-        log.Printf("Failed to create user: %v", err)  
+    if err := c.repo.CreateUser(context.Background(), user); writeAccountTaken(w, err) {
+        return
+    } else if err != nil {
+        logging.FromContext(r.Context()).Error("Failed to create user", "error", err)
         http.Error(w, fmt.Sprintf("Failed to create user: %v", err), http.StatusInternalServerError)
         return
     }
@@ -102,7 +113,7 @@ func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(user)
 }
 
-// Handles GET requests to retrieve a single user
+// Handles GET requests to retrieve a single user's profile
 func (c *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "id")
 	if userID == "" {
@@ -117,7 +128,12 @@ func (c *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	// Only the user themselves gets the full record; everyone else sees the public profile
+	if authUserID, ok := currentUserID(r); ok && authUserID == user.ID {
+		json.NewEncoder(w).Encode(user)
+		return
+	}
+	json.NewEncoder(w).Encode(user.PublicProfile())
 }
 
 // Handles GET requests to retrieve all users
@@ -141,7 +157,7 @@ func (c *UserController) GetUserProfile(w http.ResponseWriter, r *http.Request)
 
     user, err := c.repo.GetUser(r.Context(), userID)
     if err != nil {
-        log.Println("Failed to retrieve user:", err)
+        logging.FromContext(r.Context()).Error("Failed to retrieve user", "error", err)
         http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
         return
     }
@@ -159,8 +175,9 @@ func (c *UserController) UpdateUserProfile(w http.ResponseWriter, r *http.Reques
     }
 
     var updatedFields struct {
-        Bio           string `json:"bio"`
-        ProfilePicURL string `json:"profilePicUrl"`
+        Bio               string              `json:"bio"`
+        ProfilePicURL     string              `json:"profilePicUrl"`
+        ProfilePicMediaID *primitive.ObjectID `json:"profilePicMediaId"`
     }
     if err := json.NewDecoder(r.Body).Decode(&updatedFields); err != nil {
         http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -169,13 +186,29 @@ func (c *UserController) UpdateUserProfile(w http.ResponseWriter, r *http.Reques
 
     user, err := c.repo.GetUser(r.Context(), userID)
     if err != nil {
-        log.Println("Failed to retrieve user:", err)
+        logging.FromContext(r.Context()).Error("Failed to retrieve user", "error", err)
         http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
         return
     }
 
+    storedPicURL := user.ProfilePicURL
     user.Bio = updatedFields.Bio
     user.ProfilePicURL = updatedFields.ProfilePicURL
+    user.ProfilePicMediaID = updatedFields.ProfilePicMediaID
+
+    // Profile pictures must be uploads; link the square avatar crop when there is one
+    if updatedFields.ProfilePicMediaID != nil {
+        media, err := ownedImage(r, c.media, *updatedFields.ProfilePicMediaID, user.ID)
+        if err != nil {
+            http.Error(w, "Invalid profile picture: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+        user.ProfilePicURL = media.VariantURL("avatar256", "thumb")
+    } else if user.ProfilePicURL != "" && user.ProfilePicURL != storedPicURL && !strings.HasPrefix(user.ProfilePicURL, "/media/") {
+        // Links saved before uploads existed are kept until the user replaces them
+        http.Error(w, "Profile pictures must be uploaded through /api/media", http.StatusBadRequest)
+        return
+    }
 
     if err := c.repo.UpdateUser(r.Context(), *user); err != nil {
         http.Error(w, "Failed to update profile", http.StatusInternalServerError)
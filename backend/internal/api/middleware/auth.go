@@ -23,43 +23,95 @@ const (
     UsernameKey ContextKey = "username"
 )
 
-// AuthMiddleware validates the JWT token from the Authorization header and injects the user ID into the context.
+// AuthMiddleware validates the JWT from the Authorization header or the session cookie
+// and injects the user ID into the context. Cookie-authenticated requests using unsafe
+// methods also need the CSRF token.
 func AuthMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        authHeader := r.Header.Get("Authorization")
-        if authHeader == "" {
-            http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+        tokenStr, fromCookie, err := requestToken(r)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusUnauthorized)
+            return
+        }
+        if tokenStr == "" {
+            http.Error(w, "Authorization header or token cookie is required", http.StatusUnauthorized)
             return
         }
 
-        tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-        if tokenStr == authHeader || tokenStr == "" {
-            http.Error(w, "Invalid token format", http.StatusUnauthorized)
+        claims, err := parseToken(tokenStr)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusUnauthorized)
+            return
+        }
+        if fromCookie && !validCSRF(r, tokenStr) {
+            http.Error(w, "Missing or invalid CSRF token", http.StatusForbidden)
             return
         }
 
-        token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-            // Validate the alg is what we expect:
-            if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-            }
-            return []byte(os.Getenv("SECRET_KEY")), nil
-        })
+        next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+    })
+}
 
-        if err != nil {
-            http.Error(w, fmt.Sprintf("Invalid token: %v", err), http.StatusUnauthorized)
+// OptionalAuthMiddleware injects the user ID into the context when a valid token is sent,
+// but lets anonymous requests and requests with a bad token through as logged out.
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        tokenStr, fromCookie, err := requestToken(r)
+        if err != nil || tokenStr == "" {
+            next.ServeHTTP(w, r)
             return
         }
 
-        claims, ok := token.Claims.(*Claims)
-        if !ok || !token.Valid {
-            http.Error(w, "Invalid token", http.StatusUnauthorized)
+        claims, err := parseToken(tokenStr)
+        if err != nil || (fromCookie && !validCSRF(r, tokenStr)) {
+            next.ServeHTTP(w, r)
             return
         }
 
-        // Inject user ID and username into the context of each request
-        ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-        ctx = context.WithValue(ctx, UsernameKey, claims.Username) 
-        next.ServeHTTP(w, r.WithContext(ctx))
+        next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+    })
+}
+
+// Returns the request's token and whether it came from the session cookie, or "" if it
+// has neither. The Authorization header wins, since browsers never send it on their own.
+func requestToken(r *http.Request) (string, bool, error) {
+    if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+        tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+        if tokenStr == authHeader || tokenStr == "" {
+            return "", false, fmt.Errorf("Invalid token format")
+        }
+        return tokenStr, false, nil
+    }
+    if cookie, err := r.Cookie(TokenCookie); err == nil && cookie.Value != "" {
+        return cookie.Value, true, nil
+    }
+    return "", false, nil
+}
+
+// Parses and validates a JWT
+func parseToken(tokenStr string) (*Claims, error) {
+    token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+        // Validate the alg is what we expect:
+        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+            return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+        }
+        return []byte(os.Getenv("SECRET_KEY")), nil
     })
-}
\ No newline at end of file
+
+    if err != nil {
+        return nil, fmt.Errorf("Invalid token: %v", err)
+    }
+
+    claims, ok := token.Claims.(*Claims)
+    if !ok || !token.Valid {
+        return nil, fmt.Errorf("Invalid token")
+    }
+    return claims, nil
+}
+
+// Injects user ID and username into the context of a request
+func withClaims(ctx context.Context, claims *Claims) context.Context {
+    ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
+    ctx = withLogUser(ctx, claims.UserID)
+    return context.WithValue(ctx, UsernameKey, claims.Username)
+}
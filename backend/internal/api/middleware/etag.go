@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Responses larger than this are streamed without an ETag rather than held in memory
+const maxETagBody = 1 << 20
+
+// ETag adds an ETag hashed from the body to successful GET responses and answers
+// requests whose If-None-Match matches it with 304 Not Modified. The handler still
+// runs, so this saves bandwidth rather than work. Responses that set their own ETag or
+// Cache-Control: no-store, are flushed, or exceed maxETagBody are passed through.
+//
+// Responses vary with the caller's token, so shared caches are told to key on it, and
+// responses to requests carrying one must be revalidated and never stored by shared
+// caches, which could otherwise hand one user's response to another.
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Authorization, Cookie")
+		if personalised(r) {
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+		ew := &etagWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.streaming {
+			return
+		}
+
+		h := w.Header()
+		status := ew.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if status == http.StatusOK && h.Get("ETag") == "" && !strings.Contains(h.Get("Cache-Control"), "no-store") {
+			etag := fmt.Sprintf(`"%x"`, sha256.Sum256(ew.buf.Bytes()))
+			h.Set("ETag", etag)
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(status)
+		w.Write(ew.buf.Bytes())
+	})
+}
+
+// Reports whether a request carries a token that can change the response
+func personalised(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	cookie, err := r.Cookie(TokenCookie)
+	return err == nil && cookie.Value != ""
+}
+
+// Buffers the response until the handler returns, unless it is flushed or grows past
+// maxETagBody, after which it is written through
+type etagWriter struct {
+	http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+}
+
+func (w *etagWriter) WriteHeader(status int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *etagWriter) Write(p []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.buf.Len()+len(p) > maxETagBody {
+		if err := w.stream(); err != nil {
+			return 0, err
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush starts streaming, as event streams need each write delivered immediately
+func (w *etagWriter) Flush() {
+	if !w.streaming {
+		w.stream()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Writes the status and anything buffered so far, and passes later writes through
+func (w *etagWriter) stream() error {
+	w.streaming = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Reports whether an If-None-Match header lists etag. The comparison is weak, as
+// RFC 9110 requires for If-None-Match, so W/ prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+)
+
+// RequestIDHeader carries the request ID in both directions, so a proxy's ID is kept
+// and clients can quote it when reporting a problem
+const RequestIDHeader = "X-Request-ID"
+
+const RequestIDKey ContextKey = "requestID"
+
+// Incoming IDs are only reused when they can't mangle a log line
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID assigns every request an ID, echoes it in the response and adds it to the
+// request's logger.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), RequestIDKey, id)
+		ctx = logging.With(ctx, "request_id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the ID RequestID assigned to the request, or "" outside one
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Filled in by the auth middleware further down the chain, which only sees a copy of the request
+type accessEntry struct {
+	userID string
+}
+
+type accessEntryKey struct{}
+
+// AccessLog logs one line per request once it has been handled, with the matched route
+// pattern rather than the raw path so requests can be grouped and no IDs or slugs leak
+// into the log.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK // The handler wrote nothing
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", ww.BytesWritten()),
+		}
+		if entry.userID != "" {
+			attrs = append(attrs, slog.String("user_id", entry.userID))
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(r.Context()).LogAttrs(r.Context(), level, "Request handled", attrs...)
+	})
+}
+
+// Returns the pattern of the route that handled r, once it has been routed
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return "unmatched"
+}
+
+// Records the authenticated user for the access log and the request's logger
+func withLogUser(ctx context.Context, userID string) context.Context {
+	if entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.userID = userID
+	}
+	return logging.With(ctx, "user_id", userID)
+}
@@ -0,0 +1,351 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/middleware"
+)
+
+// ClientPath is where the generated client lives, relative to the repository root
+const ClientPath = "frontend/src/api/client.js"
+
+// The request helper every generated function calls. It sends the token the frontend
+// keeps in localStorage, and the CSRF token for sessions held in the cookie.
+const clientRuntime = `/** Thrown for responses with an error status; the message is the response body */
+export class ApiError extends Error {
+  constructor(status, message) {
+    super(message);
+    this.name = "ApiError";
+    this.status = status;
+  }
+}
+
+const baseURL = import.meta.env.VITE_API_URL || "";
+
+function csrfToken() {
+  const match = document.cookie.match(/(?:^|;\s*)%[1]s=([^;]*)/);
+  return match ? decodeURIComponent(match[1]) : "";
+}
+
+async function request(method, path, { query, body, form } = {}) {
+  const url = new URL(baseURL + path, window.location.origin);
+  for (const [name, value] of Object.entries(query || {})) {
+    if (value !== undefined && value !== null) url.searchParams.set(name, value);
+  }
+
+  const headers = {};
+  const token = localStorage.getItem("token");
+  if (token) headers.Authorization = ` + "`Bearer ${token}`" + `;
+  const csrf = csrfToken();
+  if (csrf && method !== "GET") headers["%[2]s"] = csrf;
+
+  let payload;
+  if (form) {
+    payload = new FormData();
+    for (const [name, value] of Object.entries(form)) {
+      if (value !== undefined && value !== null) payload.append(name, value);
+    }
+  } else if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+    payload = JSON.stringify(body);
+  }
+
+  const response = await fetch(url, { method, headers, body: payload, credentials: "include" });
+  if (!response.ok) {
+    throw new ApiError(response.status, (await response.text()).trim());
+  }
+  if ((response.headers.get("Content-Type") || "").includes("application/json")) {
+    return response.json();
+  }
+  return undefined;
+}
+`
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Client generates a JavaScript module with a function for every operation that sends
+// and receives JSON, named after its operationId, and a JSDoc type for every schema.
+// Feeds, pages and other non-JSON routes are left out.
+func Client() ([]byte, error) {
+	if specErr != nil {
+		return nil, specErr
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return nil, err
+	}
+	g := clientGenerator{spec: spec}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by \"api generate-client\" from the OpenAPI document. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, clientRuntime, middleware.CSRFCookie, middleware.CSRFHeader)
+
+	schemas := object(object(spec["components"])["schemas"])
+	for _, name := range sortedKeys(schemas) {
+		out.WriteString("\n")
+		g.typedef(&out, name, object(schemas[name]))
+	}
+
+	paths := object(spec["paths"])
+	for _, path := range sortedKeys(paths) {
+		item := object(paths[path])
+		for _, method := range methods {
+			op, ok := item[method].(map[string]interface{})
+			if !ok || !g.jsonOnly(op) {
+				continue
+			}
+			out.WriteString("\n")
+			if err := g.operation(&out, path, method, item, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
+
+type clientGenerator struct {
+	spec map[string]interface{}
+}
+
+// Writes a JSDoc typedef for a component schema
+func (g clientGenerator) typedef(out *bytes.Buffer, name string, s map[string]interface{}) {
+	out.WriteString("/**\n")
+	if description := oneLine(s["description"]); description != "" {
+		fmt.Fprintf(out, " * %s\n", description)
+	}
+	properties := object(s["properties"])
+	if len(properties) == 0 {
+		fmt.Fprintf(out, " * @typedef {%s} %s\n */\n", g.jsType(s), name)
+		return
+	}
+	fmt.Fprintf(out, " * @typedef {Object} %s\n", name)
+	required := stringSet(s["required"])
+	for _, property := range sortedKeys(properties) {
+		p := object(properties[property])
+		field := "[" + property + "]"
+		if required[property] {
+			field = property
+		}
+		line := fmt.Sprintf(" * @property {%s} %s", g.jsType(p), field)
+		if description := oneLine(p["description"]); description != "" {
+			line += " - " + description
+		}
+		out.WriteString(line + "\n")
+	}
+	out.WriteString(" */\n")
+}
+
+// Writes the function for one operation
+func (g clientGenerator) operation(out *bytes.Buffer, path, method string, item, op map[string]interface{}) error {
+	name, _ := op["operationId"].(string)
+	if name == "" {
+		return fmt.Errorf("%s %s has no operationId", strings.ToUpper(method), path)
+	}
+
+	var args, docs []string
+	if summary := oneLine(op["summary"]); summary != "" {
+		docs = append(docs, summary)
+	}
+
+	params := map[string]map[string]interface{}{}
+	var queryNames []string
+	for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+		for _, raw := range array(list) {
+			p := g.resolve(object(raw))
+			params[p["in"].(string)+":"+p["name"].(string)] = p
+			if p["in"] == "query" {
+				queryNames = append(queryNames, p["name"].(string))
+			}
+		}
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		p, ok := params["path:"+match[1]]
+		if !ok {
+			return fmt.Errorf("%s %s: path parameter %s is not declared", strings.ToUpper(method), path, match[1])
+		}
+		args = append(args, match[1])
+		docs = append(docs, fmt.Sprintf("@param {%s} %s", g.jsType(object(p["schema"])), match[1]))
+	}
+
+	var options []string
+	if body := object(op["requestBody"]); body != nil {
+		content := object(body["content"])
+		if media, ok := content["application/json"]; ok {
+			args = append(args, "body")
+			docs = append(docs, fmt.Sprintf("@param {%s} %s", g.jsType(object(object(media)["schema"])), optionalArg("body", body["required"] == true)))
+			options = append(options, "body")
+		} else if media, ok := content["multipart/form-data"]; ok {
+			args = append(args, "form")
+			docs = append(docs, fmt.Sprintf("@param {%s} form", g.jsType(object(object(media)["schema"]))))
+			options = append(options, "form")
+		}
+	}
+	if len(queryNames) > 0 {
+		fields := make([]string, len(queryNames))
+		for i, q := range queryNames {
+			fields[i] = q + "?: " + g.jsType(object(params["query:"+q]["schema"]))
+		}
+		args = append(args, "query = {}")
+		docs = append(docs, fmt.Sprintf("@param {{%s}} [query]", strings.Join(fields, ", ")))
+		options = append(options, "query")
+	}
+	docs = append(docs, fmt.Sprintf("@returns {Promise<%s>}", g.resultType(op)))
+
+	url := `"` + path + `"`
+	if strings.Contains(path, "{") {
+		url = "`" + pathParam.ReplaceAllString(path, "$${encodeURIComponent($1)}") + "`"
+	}
+	call := fmt.Sprintf("request(%q, %s)", strings.ToUpper(method), url)
+	if len(options) > 0 {
+		call = fmt.Sprintf("request(%q, %s, { %s })", strings.ToUpper(method), url, strings.Join(options, ", "))
+	}
+
+	out.WriteString("/**\n")
+	for _, line := range docs {
+		fmt.Fprintf(out, " * %s\n", line)
+	}
+	out.WriteString(" */\n")
+	fmt.Fprintf(out, "export function %s(%s) {\n  return %s;\n}\n", name, strings.Join(args, ", "), call)
+	return nil
+}
+
+// Reports whether an operation only sends and receives JSON, multipart uploads or nothing
+func (g clientGenerator) jsonOnly(op map[string]interface{}) bool {
+	if body := object(op["requestBody"]); body != nil {
+		for mediaType := range object(body["content"]) {
+			if mediaType != "application/json" && mediaType != "multipart/form-data" {
+				return false
+			}
+		}
+	}
+	for status, raw := range object(op["responses"]) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		for mediaType := range object(g.resolve(object(raw))["content"]) {
+			if mediaType != "application/json" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Returns the type of the first successful response's body
+func (g clientGenerator) resultType(op map[string]interface{}) string {
+	responses := object(op["responses"])
+	for _, status := range sortedKeys(responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		content := object(g.resolve(object(responses[status]))["content"])
+		if media, ok := content["application/json"]; ok {
+			return g.jsType(object(object(media)["schema"]))
+		}
+	}
+	return "void"
+}
+
+// Converts a schema to a JSDoc type expression
+func (g clientGenerator) jsType(s map[string]interface{}) string {
+	if ref, ok := s["$ref"].(string); ok {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	if enum := array(s["enum"]); len(enum) > 0 {
+		values := make([]string, len(enum))
+		for i, v := range enum {
+			values[i] = fmt.Sprintf("%q", v)
+		}
+		return "(" + strings.Join(values, "|") + ")"
+	}
+	switch s["type"] {
+	case "string":
+		if s["format"] == "binary" {
+			return "Blob"
+		}
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		return "Array<" + g.jsType(object(s["items"])) + ">"
+	case "object":
+		if properties := object(s["properties"]); len(properties) > 0 {
+			required := stringSet(s["required"])
+			fields := make([]string, 0, len(properties))
+			for _, name := range sortedKeys(properties) {
+				optional := "?"
+				if required[name] {
+					optional = ""
+				}
+				fields = append(fields, name+optional+": "+g.jsType(object(properties[name])))
+			}
+			return "{" + strings.Join(fields, ", ") + "}"
+		}
+		if additional := object(s["additionalProperties"]); additional != nil {
+			return "Object<string, " + g.jsType(additional) + ">"
+		}
+		return "Object"
+	}
+	return "*"
+}
+
+// Follows a $ref to a component, such as a shared parameter or response
+func (g clientGenerator) resolve(v map[string]interface{}) map[string]interface{} {
+	ref, ok := v["$ref"].(string)
+	if !ok {
+		return v
+	}
+	target := interface{}(g.spec)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		target = object(target)[part]
+	}
+	return object(target)
+}
+
+func optionalArg(name string, required bool) string {
+	if required {
+		return name
+	}
+	return "[" + name + "]"
+}
+
+func object(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func array(v interface{}) []interface{} {
+	a, _ := v.([]interface{})
+	return a
+}
+
+func stringSet(v interface{}) map[string]bool {
+	set := map[string]bool{}
+	for _, s := range array(v) {
+		if name, ok := s.(string); ok {
+			set[name] = true
+		}
+	}
+	return set
+}
+
+func oneLine(v interface{}) string {
+	s, _ := v.(string)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
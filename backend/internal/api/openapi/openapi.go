@@ -0,0 +1,183 @@
+// Package openapi serves the API's OpenAPI 3 description and checks it against the
+// router and models so the document can't silently fall out of date.
+//
+// The specification is maintained by hand in openapi.yaml. It is served as JSON at
+// /api/openapi.json and rendered by a small documentation page at /api/docs.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/csp"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+//go:embed docs.html
+var docsHTML []byte
+
+// Routes that serve the frontend rather than the API, so the document leaves them out
+var undocumented = map[string]bool{
+	"/*":            true,
+	"/public/*":     true,
+	"/dashboard":    true,
+	"/posts/{slug}": true,
+}
+
+var methods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+type document struct {
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Properties map[string]interface{} `json:"properties"`
+}
+
+var specJSON, specErr = convert(specYAML)
+
+// Converts the YAML document to JSON, which is what OpenAPI tooling expects to fetch
+func convert(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid openapi.yaml: %w", err)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Spec returns the OpenAPI document as JSON
+func Spec() ([]byte, error) {
+	return specJSON, specErr
+}
+
+// ServeSpec writes the OpenAPI document
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	if specErr != nil {
+		http.Error(w, "API specification unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
+
+// ServeDocs writes the documentation page, which loads the document from /api/openapi.json.
+// Its inline script and style get the request's CSP nonce.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(csp.Inject(docsHTML, csp.Nonce(r.Context())))
+}
+
+// Check compares the document against the router and the Go types behind its schemas.
+// types maps a schema name to a value of the type it describes. It returns one line per
+// difference: routes that are registered but undocumented or documented but missing,
+// and schema properties that don't match the type's JSON fields.
+func Check(routes chi.Routes, types map[string]interface{}) ([]string, error) {
+	if specErr != nil {
+		return nil, specErr
+	}
+	var doc document
+	if err := json.Unmarshal(specJSON, &doc); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for _, method := range methods {
+			if _, ok := item[method]; ok {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	registered := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if undocumented[route] {
+			return nil
+		}
+		// Wildcard routes are documented with a named parameter
+		if strings.HasSuffix(route, "/*") {
+			route = strings.TrimSuffix(route, "*") + "{path}"
+		}
+		registered[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for route := range registered {
+		if !documented[route] {
+			problems = append(problems, "route not documented: "+route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			problems = append(problems, "documented route not registered: "+route)
+		}
+	}
+
+	for name, value := range types {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			problems = append(problems, "schema missing: "+name)
+			continue
+		}
+		fields := map[string]bool{}
+		jsonFields(reflect.TypeOf(value), fields)
+		for field := range fields {
+			if _, ok := s.Properties[field]; !ok {
+				problems = append(problems, fmt.Sprintf("schema %s: property %q not documented", name, field))
+			}
+		}
+		for property := range s.Properties {
+			if !fields[property] {
+				problems = append(problems, fmt.Sprintf("schema %s: property %q not in %T", name, property, value))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// Collects the names encoding/json uses for a struct's fields, including promoted ones
+func jsonFields(t reflect.Type, fields map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			jsonFields(f.Type, fields)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+}
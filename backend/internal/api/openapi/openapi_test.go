@@ -0,0 +1,544 @@
+package openapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/api"
+	"github.com/DavAnders/odin-blogapi/backend/internal/api/openapi"
+	"github.com/DavAnders/odin-blogapi/backend/internal/health"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/storage"
+	"github.com/DavAnders/odin-blogapi/backend/pkg/jwt"
+)
+
+func TestCheck(t *testing.T) {
+	app, _ := newTestApp(t)
+	problems, err := openapi.Check(app.Router, api.Schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
+
+// Each handler's response must have a documented status and match the documented schema
+func TestResponsesMatchSchemas(t *testing.T) {
+	app, data := newTestApp(t)
+	spec := loadSpec(t)
+
+	token, err := jwt.GenerateToken(data.user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postID := data.post.ID.Hex()
+	userID := data.user.ID.Hex()
+
+	tests := []struct {
+		method string
+		path   string // As written in the document
+		url    string
+		body   string
+		auth   bool
+	}{
+		{method: "POST", path: "/login", url: "/login", body: `{"username":"alice","password":"correct horse"}`},
+		{method: "POST", path: "/login", url: "/login", body: `{"username":"alice","password":"wrong"}`},
+		{method: "POST", path: "/logout", url: "/logout"},
+		{method: "POST", path: "/register", url: "/register", body: `{"username":"alice","email":"a@example.com","password":"pw"}`},
+		{method: "POST", path: "/register", url: "/register", body: `{"username":"alicia","email":"alice@example.com","password":"pw"}`},
+		{method: "GET", path: "/api/posts", url: "/api/posts"},
+		{method: "GET", path: "/api/posts", url: "/api/posts?limit=5", auth: true},
+		{method: "GET", path: "/api/posts/{id}", url: "/api/posts/" + postID},
+		{method: "GET", path: "/api/posts/{id}", url: "/api/posts/" + primitive.NewObjectID().Hex()},
+		{method: "POST", path: "/api/posts", url: "/api/posts", body: `{"title":"Second","content":"Hello @alice","tags":["Go"]}`, auth: true},
+		{method: "POST", path: "/api/posts", url: "/api/posts", body: `{"title":"No token"}`},
+		{method: "GET", path: "/api/comments/{id}", url: "/api/comments/" + postID},
+		{method: "GET", path: "/api/comments/{id}", url: "/api/comments/not-an-id"},
+		{method: "POST", path: "/api/comments", url: "/api/comments", body: `{"postId":"` + postID + `","content":"Nice"}`, auth: true},
+		{method: "PUT", path: "/api/posts/{id}/reactions/{type}", url: "/api/posts/" + postID + "/reactions/like", auth: true},
+		{method: "PUT", path: "/api/posts/{id}/reactions/{type}", url: "/api/posts/" + primitive.NewObjectID().Hex() + "/reactions/like", auth: true},
+		{method: "GET", path: "/api/users", url: "/api/users", auth: true},
+		{method: "POST", path: "/api/users", url: "/api/users", body: `{"username":"bob","email":"alice@example.com","password":"pw"}`, auth: true},
+		{method: "GET", path: "/api/users/{id}", url: "/api/users/" + userID},
+		{method: "GET", path: "/api/profile", url: "/api/profile", auth: true},
+		{method: "GET", path: "/api/feed", url: "/api/feed", auth: true},
+		{method: "GET", path: "/api/notifications", url: "/api/notifications", auth: true},
+		{method: "GET", path: "/api/notifications/unread-count", url: "/api/notifications/unread-count", auth: true},
+		{method: "GET", path: "/api/media", url: "/api/media", auth: true},
+		{method: "GET", path: "/api/media/{id}", url: "/api/media/" + data.othersMedia.ID.Hex(), auth: true},
+		{method: "DELETE", path: "/api/media/{id}", url: "/api/media/" + data.othersMedia.ID.Hex(), auth: true},
+		{method: "GET", path: "/healthz", url: "/healthz"},
+		{method: "GET", path: "/version", url: "/version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			app.Router.ServeHTTP(rec, req)
+
+			for _, problem := range spec.checkResponse(tt.method, tt.path, rec) {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+// The committed client must be regenerated whenever the document changes
+func TestClientUpToDate(t *testing.T) {
+	path := filepath.Join("..", "..", "..", "..", filepath.FromSlash(openapi.ClientPath))
+	committed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Skip("frontend not checked out")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := openapi.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(committed, generated) {
+		t.Errorf("%s is out of date; run: go run ./cmd/api generate-client -o ../%s", openapi.ClientPath, openapi.ClientPath)
+	}
+}
+
+type testData struct {
+	user        model.User
+	post        model.Post
+	othersMedia model.Media // Uploaded by someone else
+}
+
+// Builds the application on in-memory repositories holding one user with one post
+func newTestApp(t *testing.T) (*api.App, testData) {
+	t.Helper()
+	t.Setenv("SECRET_KEY", "test secret")
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := model.User{
+		ID:             primitive.NewObjectID(),
+		Username:       "alice",
+		Email:          "alice@example.com",
+		HashedPassword: string(hashed),
+		Author:         true,
+		CreatedAt:      now,
+	}
+	post := model.Post{
+		ID:             primitive.NewObjectID(),
+		Title:          "First",
+		Slug:           "first",
+		Content:        "Hello",
+		PublishedAt:    now,
+		AuthorID:       user.ID,
+		AuthorUsername: user.Username,
+		Tags:           []string{"go"},
+		ReactionCounts: map[string]int64{"like": 1},
+	}
+	comment := model.Comment{
+		ID:        primitive.NewObjectID(),
+		PostID:    post.ID,
+		Author:    "bob",
+		AuthorID:  primitive.NewObjectID(),
+		Email:     "bob@example.com",
+		Content:   "Hi @alice",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	others := model.Media{ID: primitive.NewObjectID(), OwnerID: comment.AuthorID, Key: "3f/3f.jpg", ContentType: "image/jpeg", CreatedAt: now}
+
+	mediaStorage, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := &fakePosts{posts: []model.Post{post}}
+	app, err := api.New(api.Config{
+		Repositories: api.Repositories{
+			Posts:         posts,
+			Users:         &fakeUsers{user: user},
+			Comments:      &fakeComments{comments: []model.Comment{comment}},
+			Reactions:     &fakeReactions{posts: posts},
+			Follows:       &fakeFollows{following: []primitive.ObjectID{user.ID}},
+			Notifications: &fakeNotifications{},
+			Media:         &fakeMedia{},
+			Admins:        &fakeAdmins{},
+		},
+		Storage:             mediaStorage,
+		MediaMaxBytes:       1 << 20,
+		DeletionGracePeriod: 24 * time.Hour,
+		Frontend:            fstest.MapFS{"index.html": {Data: []byte("<!doctype html><title>Blog</title>")}},
+		SiteURL:             "http://localhost:8080",
+		SiteTitle:           "Blog",
+		Health:              health.NewChecker(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, testData{user: user, post: post, othersMedia: others}
+}
+
+// The fakes implement what the exercised handlers call; anything else panics on the
+// nil embedded interface, which shows up as a failing test.
+
+type fakeUsers struct {
+	repository.UserRepository
+	user model.User
+}
+
+func (f *fakeUsers) GetUser(ctx context.Context, id string) (*model.User, error) {
+	if id != f.user.ID.Hex() {
+		return nil, mongo.ErrNoDocuments
+	}
+	user := f.user
+	return &user, nil
+}
+
+func (f *fakeUsers) GetUsers(ctx context.Context) ([]repository.UserProjection, error) {
+	return []repository.UserProjection{{Username: f.user.Username, CreatedAt: f.user.CreatedAt}}, nil
+}
+
+func (f *fakeUsers) GetUserByUsername(ctx context.Context, username string) (model.User, error) {
+	if username != f.user.Username {
+		return model.User{}, fmt.Errorf("user not found")
+	}
+	return f.user, nil
+}
+
+// Rejects users that would break the unique indexes, as MongoDB does
+func (f *fakeUsers) CreateUser(ctx context.Context, user model.User) error {
+	switch {
+	case user.Username == f.user.Username:
+		return fmt.Errorf("%w: E11000 duplicate key error", repository.ErrUsernameTaken)
+	case user.Email == f.user.Email:
+		return fmt.Errorf("%w: E11000 duplicate key error", repository.ErrEmailTaken)
+	}
+	return nil
+}
+
+func (f *fakeUsers) ValidateCredentials(ctx context.Context, username, password string) (*model.User, error) {
+	if username != f.user.Username || bcrypt.CompareHashAndPassword([]byte(f.user.HashedPassword), []byte(password)) != nil {
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	user := f.user
+	return &user, nil
+}
+
+type fakePosts struct {
+	repository.PostRepository
+	posts []model.Post
+}
+
+func (f *fakePosts) CreatePost(ctx context.Context, post *model.Post) error {
+	post.ID = primitive.NewObjectID()
+	post.Slug = strings.ToLower(post.Title)
+	f.posts = append(f.posts, *post)
+	return nil
+}
+
+func (f *fakePosts) GetPosts(ctx context.Context, filter bson.M, limit int64, skip int64) ([]model.Post, error) {
+	return append([]model.Post{}, f.posts...), nil
+}
+
+func (f *fakePosts) GetPostByID(ctx context.Context, id string) (*model.Post, error) {
+	for _, post := range f.posts {
+		if post.ID.Hex() == id {
+			return &post, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+type fakeComments struct {
+	repository.CommentRepository
+	comments []model.Comment
+}
+
+func (f *fakeComments) CreateComment(ctx context.Context, comment model.Comment) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func (f *fakeComments) GetCommentsByPost(ctx context.Context, postID primitive.ObjectID) ([]model.Comment, error) {
+	var comments []model.Comment
+	for _, comment := range f.comments {
+		if comment.PostID == postID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+type fakeReactions struct {
+	repository.ReactionRepository
+	posts *fakePosts
+}
+
+func (f *fakeReactions) AddReaction(ctx context.Context, reaction model.Reaction) (bool, error) {
+	if _, err := f.posts.GetPostByID(ctx, reaction.TargetID.Hex()); err != nil {
+		return false, fmt.Errorf("no post found with given ID: %w", repository.ErrReactionTargetNotFound)
+	}
+	return true, nil
+}
+
+func (f *fakeReactions) GetUserReactions(ctx context.Context, targetType string, targetIDs []primitive.ObjectID, userID primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	mine := map[primitive.ObjectID][]string{}
+	for _, id := range targetIDs {
+		mine[id] = []string{"like"}
+	}
+	return mine, nil
+}
+
+type fakeFollows struct {
+	repository.FollowRepository
+	following []primitive.ObjectID
+}
+
+func (f *fakeFollows) GetFollowingIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	return f.following, nil
+}
+
+type fakeNotifications struct {
+	repository.NotificationRepository
+	notifications []model.Notification
+}
+
+func (f *fakeNotifications) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	notification.CreatedAt = time.Now()
+	f.notifications = append(f.notifications, *notification)
+	return nil
+}
+
+func (f *fakeNotifications) GetNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int64, skip int64) ([]model.Notification, error) {
+	notifications := []model.Notification{}
+	for _, n := range f.notifications {
+		if n.UserID == userID {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (f *fakeNotifications) CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	notifications, _ := f.GetNotifications(ctx, userID, true, 0, 0)
+	return int64(len(notifications)), nil
+}
+
+type fakeMedia struct {
+	repository.MediaRepository
+	media []model.Media
+}
+
+func (f *fakeMedia) GetMedia(ctx context.Context, id primitive.ObjectID) (*model.Media, error) {
+	for _, media := range f.media {
+		if media.ID == id {
+			return &media, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (f *fakeMedia) GetMediaByOwner(ctx context.Context, ownerID primitive.ObjectID, limit int64, skip int64) ([]model.Media, error) {
+	return []model.Media{}, nil
+}
+
+type fakeAdmins struct {
+	repository.AdminRepository
+}
+
+func (f *fakeAdmins) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	return false, nil
+}
+
+// A minimal validator for the subset of OpenAPI schemas the document uses
+
+type testSpec struct {
+	doc map[string]interface{}
+}
+
+func loadSpec(t *testing.T) testSpec {
+	t.Helper()
+	data, err := openapi.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return testSpec{doc: doc}
+}
+
+// Returns how a recorded response differs from the one documented for the operation
+func (s testSpec) checkResponse(method, path string, rec *httptest.ResponseRecorder) []string {
+	op := object(object(object(s.doc["paths"])[path])[strings.ToLower(method)])
+	if op == nil {
+		return []string{fmt.Sprintf("%s %s is not documented", method, path)}
+	}
+	responses := object(op["responses"])
+	response, ok := responses[strconv.Itoa(rec.Code)]
+	if !ok {
+		return []string{fmt.Sprintf("status %d is not documented (body %q)", rec.Code, rec.Body.String())}
+	}
+	content := object(object(s.resolve(response))["content"])
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			return []string{fmt.Sprintf("status %d is documented without a body, got %q", rec.Code, rec.Body.String())}
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := content[mediaType]
+	if !ok {
+		return []string{fmt.Sprintf("status %d: content type %q is not documented", rec.Code, mediaType)}
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return []string{fmt.Sprintf("invalid JSON body: %v", err)}
+	}
+	return s.validate(object(media)["schema"], body, "body")
+}
+
+// Returns every way value doesn't match schema
+func (s testSpec) validate(schema, value interface{}, at string) []string {
+	sch := object(s.resolve(schema))
+	if sch == nil {
+		return nil
+	}
+	if value == nil {
+		if sch["nullable"] == true {
+			return nil
+		}
+		return []string{at + ": null"}
+	}
+	if enum, ok := sch["enum"].([]interface{}); ok {
+		found := false
+		for _, v := range enum {
+			if v == value {
+				found = true
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, enum)}
+		}
+	}
+
+	var problems []string
+	switch sch["type"] {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a string, got %T", at, value)}
+		}
+		if pattern, ok := sch["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+			problems = append(problems, fmt.Sprintf("%s: %q doesn't match %s", at, str, pattern))
+		}
+		if sch["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, str))
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a number, got %T", at, value)}
+		}
+		if sch["type"] == "integer" && n != float64(int64(n)) {
+			problems = append(problems, fmt.Sprintf("%s: %v is not an integer", at, n))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected a boolean, got %T", at, value)}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array, got %T", at, value)}
+		}
+		for i, item := range items {
+			problems = append(problems, s.validate(sch["items"], item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %T", at, value)}
+		}
+		properties := object(sch["properties"])
+		for _, name := range stringList(sch["required"]) {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %q", at, name))
+			}
+		}
+		for name, v := range obj {
+			if property, ok := properties[name]; ok {
+				problems = append(problems, s.validate(property, v, at+"."+name)...)
+			} else if additional, ok := sch["additionalProperties"]; ok && additional != false {
+				problems = append(problems, s.validate(additional, v, at+"."+name)...)
+			} else if len(properties) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: undocumented property %q", at, name))
+			}
+		}
+	}
+	return problems
+}
+
+// Follows a $ref within the document
+func (s testSpec) resolve(v interface{}) interface{} {
+	ref, ok := object(v)["$ref"].(string)
+	if !ok {
+		return v
+	}
+	target := interface{}(s.doc)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		target = object(target)[part]
+	}
+	return s.resolve(target)
+}
+
+func object(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func stringList(v interface{}) []string {
+	var list []string
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
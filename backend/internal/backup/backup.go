@@ -0,0 +1,322 @@
+// Package backup writes the database to a portable archive and restores it.
+//
+// An archive is a gzipped tar file holding manifest.json followed by one
+// <collection>.jsonl file per collection. Each line is a document in canonical
+// MongoDB Extended JSON, so ObjectIDs and dates survive the round trip.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Version is the archive format written by Write. Restore accepts this version and older ones.
+const Version = 1
+
+// Documents are inserted in batches of this size when restoring
+const insertBatchSize = 500
+
+// Collection is a named collection included in a backup
+type Collection struct {
+	Name       string
+	Collection *mongo.Collection
+}
+
+// Manifest describes an archive's contents
+type Manifest struct {
+	Version     int              `json:"version"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	Collections map[string]int64 `json:"collections"` // Document count per collection
+}
+
+// Write dumps every document in collections to w as a gzipped tar archive. Collections
+// are spooled to temporary files first, since the manifest at the start of the archive
+// lists their document counts and tar needs each file's size up front; memory use stays
+// flat, but the temporary directory needs room for the uncompressed dump.
+func Write(ctx context.Context, collections []Collection, w io.Writer) (*Manifest, error) {
+	manifest := &Manifest{
+		Version:     Version,
+		CreatedAt:   time.Now().UTC(),
+		Collections: map[string]int64{},
+	}
+
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	for _, c := range collections {
+		f, err := os.CreateTemp("", "backup-*.jsonl")
+		if err != nil {
+			return nil, err
+		}
+		files[c.Name] = f
+		buf := bufio.NewWriter(f)
+		count, err := dumpCollection(ctx, c.Collection, buf)
+		if err == nil {
+			err = buf.Flush()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dumping %s: %w", c.Name, err)
+		}
+		manifest.Collections[c.Name] = count
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, "manifest.json", bytes.NewReader(manifestJSON), int64(len(manifestJSON)), manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, c := range collections {
+		f := files[c.Name]
+		size, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := writeFile(tw, c.Name+".jsonl", f, size, manifest.CreatedAt); err != nil {
+			return nil, fmt.Errorf("writing %s: %w", c.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+func dumpCollection(ctx context.Context, collection *mongo.Collection, w io.Writer) (int64, error) {
+	cur, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var count int64
+	for cur.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cur.Current, true, false)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, cur.Err()
+}
+
+func writeFile(tw *tar.Writer, name string, data io.Reader, size int64, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, data)
+	return err
+}
+
+// Restore loads an archive written by Write into collections, which must all be empty.
+// Every document gets a new ObjectID, and references to old IDs anywhere in the
+// restored documents are rewritten to match. Collections missing from the archive are
+// left empty; files for unknown collections are ignored. If an insert fails, the
+// documents already restored are deleted again.
+//
+// Remapping needs every old ID before the first insert, so the whole archive is decoded
+// into memory: expect memory use of a few times the uncompressed dump.
+func Restore(ctx context.Context, collections []Collection, r io.Reader) (*Manifest, error) {
+	for _, c := range collections {
+		count, err := c.Collection.CountDocuments(ctx, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("collection %s is not empty; restore only runs against an empty database", c.Name)
+		}
+	}
+
+	manifest, docs, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs are remapped in two passes because documents reference each other in both directions,
+	// e.g. users point at their avatar media and media points at its owner
+	ids := map[primitive.ObjectID]primitive.ObjectID{}
+	for _, collectionDocs := range docs {
+		for _, doc := range collectionDocs {
+			if id, ok := doc.Map()["_id"].(primitive.ObjectID); ok {
+				ids[id] = primitive.NewObjectID()
+			}
+		}
+	}
+
+	// Every restored document has a new ID, so a failed restore can remove exactly what
+	// it inserted and leave the database empty for another attempt
+	inserted := map[string][]interface{}{}
+	for _, c := range collections {
+		collectionDocs := docs[c.Name]
+		for start := 0; start < len(collectionDocs); start += insertBatchSize {
+			end := start + insertBatchSize
+			if end > len(collectionDocs) {
+				end = len(collectionDocs)
+			}
+			batch := make([]interface{}, 0, end-start)
+			for _, doc := range collectionDocs[start:end] {
+				restored := remap(doc, ids).(bson.D)
+				batch = append(batch, restored)
+				if id, ok := restored.Map()["_id"]; ok {
+					inserted[c.Name] = append(inserted[c.Name], id)
+				}
+			}
+			if _, err := c.Collection.InsertMany(ctx, batch); err != nil {
+				err = fmt.Errorf("restoring %s: %w", c.Name, err)
+				if rollbackErr := rollback(context.WithoutCancel(ctx), collections, inserted); rollbackErr != nil {
+					return nil, fmt.Errorf("%w (rolling back: %v)", err, rollbackErr)
+				}
+				return nil, err
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// Deletes the documents a failed restore inserted, batch by batch
+func rollback(ctx context.Context, collections []Collection, inserted map[string][]interface{}) error {
+	for _, c := range collections {
+		ids := inserted[c.Name]
+		for start := 0; start < len(ids); start += insertBatchSize {
+			end := start + insertBatchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			if _, err := c.Collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids[start:end]}}); err != nil {
+				return fmt.Errorf("%s: %w", c.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the manifest and the documents of every collection file in an archive, all held in memory
+func read(r io.Reader) (*Manifest, map[string][]bson.D, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var manifest *Manifest
+	docs := map[string][]bson.D{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch name := path.Base(header.Name); {
+		case name == "manifest.json":
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, nil, fmt.Errorf("reading manifest: %w", err)
+			}
+			if manifest.Version > Version {
+				return nil, nil, fmt.Errorf("archive version %d is newer than the supported version %d", manifest.Version, Version)
+			}
+		case strings.HasSuffix(name, ".jsonl"):
+			collection := strings.TrimSuffix(name, ".jsonl")
+			if docs[collection], err = readLines(tr); err != nil {
+				return nil, nil, fmt.Errorf("reading %s: %w", name, err)
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("archive has no manifest.json")
+	}
+	for name, count := range manifest.Collections {
+		if int64(len(docs[name])) != count {
+			return nil, nil, fmt.Errorf("archive is incomplete: %s has %d documents, manifest lists %d", name, len(docs[name]), count)
+		}
+	}
+	return manifest, docs, nil
+}
+
+func readLines(r io.Reader) ([]bson.D, error) {
+	var docs []bson.D
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20) // Documents are capped at 16 MB
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(line, true, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, scanner.Err()
+}
+
+// Returns value with every ObjectID found in ids replaced by its new ID. Hex strings of
+// known IDs are replaced too, since some older documents store references as strings.
+func remap(value interface{}, ids map[primitive.ObjectID]primitive.ObjectID) interface{} {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		if id, ok := ids[v]; ok {
+			return id
+		}
+	case string:
+		if old, err := primitive.ObjectIDFromHex(v); err == nil {
+			if id, ok := ids[old]; ok {
+				return id.Hex()
+			}
+		}
+	case bson.D:
+		out := make(bson.D, len(v))
+		for i, e := range v {
+			out[i] = bson.E{Key: e.Key, Value: remap(e.Value, ids)}
+		}
+		return out
+	case bson.A:
+		out := make(bson.A, len(v))
+		for i, e := range v {
+			out[i] = remap(e, ids)
+		}
+		return out
+	}
+	return value
+}
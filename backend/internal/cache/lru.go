@@ -0,0 +1,109 @@
+// Package cache keeps recently read values in memory for a limited time, so hot read
+// paths don't query MongoDB on every request.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/metrics"
+)
+
+// LRU holds up to size values for at most ttl each, evicting the least recently used
+// value when full. It is safe for concurrent use.
+type LRU struct {
+	name  string
+	size  int
+	ttl   time.Duration
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // Most recently used at the front
+	gen   uint64     // Incremented by Purge
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// New returns an LRU named name in metrics. It stores nothing if size or ttl is not
+// positive, which turns caching off.
+func New(name string, size int, ttl time.Duration) *LRU {
+	return &LRU{
+		name:  name,
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *LRU) enabled() bool {
+	return c.size > 0 && c.ttl > 0
+}
+
+// Load returns the value cached under key, or calls fn and caches what it returns.
+// Errors are not cached. A value fn read before a concurrent Purge is returned but
+// not cached, so it can't outlive the write that caused the purge.
+func (c *LRU) Load(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if !c.enabled() {
+		return fn()
+	}
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		if time.Now().Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			metrics.ObserveCache(c.name, true)
+			return e.value, nil
+		}
+		c.remove(el)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	metrics.ObserveCache(c.name, false)
+
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return value, nil
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return value, nil
+}
+
+// Purge removes every value
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Len returns the number of values held, including expired ones not yet evicted
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,46 @@
+// Package csp carries the per-request Content Security Policy nonce from the security
+// headers middleware to the handlers that write HTML, and receives the violation
+// reports browsers send when the policy blocks something.
+package csp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// Placeholder marks the nonce attributes of inline script and style elements. Vite
+// writes it into the built index.html (html.cspNonce in vite.config.js), and the
+// server's own pages carry it by hand. Inject replaces it with the request's nonce.
+const Placeholder = "__CSP_NONCE__"
+
+type nonceKey struct{}
+
+// NewNonce returns a random nonce for a single response
+func NewNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// WithNonce returns a copy of ctx carrying the nonce the response's policy allows
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, nonceKey{}, nonce)
+}
+
+// Nonce returns the request's nonce, or "" if its policy doesn't use one
+func Nonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// Inject replaces every Placeholder in page with nonce. Elements without the
+// placeholder are left alone, so markup that reached the page any other way stays
+// blocked. Returns page unchanged if nonce is "".
+func Inject(page []byte, nonce string) []byte {
+	if nonce == "" {
+		return page
+	}
+	return bytes.ReplaceAll(page, []byte(Placeholder), []byte(nonce))
+}
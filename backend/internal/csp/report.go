@@ -0,0 +1,85 @@
+package csp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+)
+
+// Reports are a few hundred bytes; anything much larger isn't from a browser
+const maxReportBytes = 64 << 10
+
+// A violation as sent to report-uri, in an application/csp-report body
+type legacyReport struct {
+	Body struct {
+		DocumentURI        string `json:"document-uri"`
+		BlockedURI         string `json:"blocked-uri"`
+		EffectiveDirective string `json:"effective-directive"`
+		ViolatedDirective  string `json:"violated-directive"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+	} `json:"csp-report"`
+}
+
+// A violation as sent through the Reporting API to report-to, in an
+// application/reports+json body holding a list of reports
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+	} `json:"body"`
+}
+
+// Report handles POST requests carrying CSP violation reports in either the report-uri
+// or the Reporting API format, and logs each violation
+func Report(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportBytes))
+	if err != nil {
+		http.Error(w, "Report too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	logger := logging.FromContext(r.Context())
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/reports+json") {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			b := report.Body
+			logger.Warn("Content Security Policy violation",
+				"document_uri", b.DocumentURL, "blocked_uri", b.BlockedURL, "directive", b.EffectiveDirective,
+				"disposition", b.Disposition, "source_file", b.SourceFile, "line", b.LineNumber)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var report legacyReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+	b := report.Body
+	directive := b.EffectiveDirective
+	if directive == "" {
+		directive = b.ViolatedDirective
+	}
+	logger.Warn("Content Security Policy violation",
+		"document_uri", b.DocumentURI, "blocked_uri", b.BlockedURI, "directive", directive,
+		"disposition", b.Disposition, "source_file", b.SourceFile, "line", b.LineNumber)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,173 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+)
+
+// Channel describes the feed as a whole
+type Channel struct {
+	Title       string
+	Description string
+	SiteURL     string // Base URL of the site, without a trailing slash
+	FeedURL     string // Absolute URL the feed itself is served from
+	Updated     time.Time
+}
+
+// Returns the permalink for a post on the site
+func PostURL(siteURL string, post model.Post) string {
+	if post.Slug != "" {
+		return siteURL + "/posts/" + post.Slug
+	}
+	return siteURL + "/posts/" + post.ID.Hex()
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders posts as an RSS 2.0 document
+func RSS(channel Channel, posts []model.Post) ([]byte, error) {
+	doc := rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       channel.Title,
+			Link:        channel.SiteURL,
+			Description: channel.Description,
+			AtomLink:    atomLink{Href: channel.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !channel.Updated.IsZero() {
+		doc.Channel.LastBuildDate = channel.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, post := range posts {
+		link := PostURL(channel.SiteURL, post)
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			Author:      post.AuthorUsername,
+			Categories:  post.Tags,
+			PubDate:     post.PublishedAt.UTC().Format(time.RFC1123Z),
+			Description: post.Content,
+		})
+	}
+	return marshal(doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Links      []atomLink     `xml:"link"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomText       `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom renders posts as an Atom 1.0 document
+func Atom(channel Channel, posts []model.Post) ([]byte, error) {
+	updated := channel.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0) // Empty feed; keep the output stable so validators still match
+	}
+	doc := atomFeed{
+		ID:      channel.FeedURL,
+		Title:   channel.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: channel.SiteURL, Rel: "alternate", Type: "text/html"},
+			{Href: channel.FeedURL, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, post := range posts {
+		link := PostURL(channel.SiteURL, post)
+		published := post.PublishedAt.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			ID:        link,
+			Title:     post.Title,
+			Updated:   published,
+			Published: published,
+			Links:     []atomLink{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Author:    atomPerson{Name: post.AuthorUsername},
+			Content:   atomText{Type: "html", Value: post.Content},
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshal(doc)
+}
+
+func marshal(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.Write(body)
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,126 @@
+// Package health serves the liveness, readiness and build information endpoints that
+// orchestrators and load balancers poll.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+)
+
+// Check reports whether a dependency is usable; it should return promptly once ctx is done
+type Check func(ctx context.Context) error
+
+// Degraded wraps the error of a check that found something worth fixing but that
+// doesn't stop the instance serving. It is reported as "degraded" and leaves the
+// instance ready.
+func Degraded(err error) error {
+	return degradedError{err}
+}
+
+type degradedError struct{ error }
+
+func (e degradedError) Unwrap() error { return e.error }
+
+// Status is the body of a readiness response
+type Status struct {
+	Status string            `json:"status"` // "ok" or "unavailable"
+	Checks map[string]string `json:"checks"` // "ok", "degraded" or "failed" for each check
+}
+
+// Checker runs the readiness checks. It also tracks shutdown, so instances stop
+// receiving traffic while they drain.
+type Checker struct {
+	timeout      time.Duration
+	mu           sync.Mutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewChecker returns a Checker that gives each check timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a readiness check under name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// ShuttingDown marks the instance as draining; it reports unready from then on
+func (c *Checker) ShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Live handles GET /healthz. The process answering at all means it is alive, so this
+// never checks dependencies; a database outage shouldn't get every instance restarted.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+// Ready handles GET /readyz by running every check concurrently
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	status := Status{Status: "ok", Checks: make(map[string]string, len(checks)+1)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			var degraded degradedError
+			if errors.As(err, &degraded) {
+				logging.FromContext(r.Context()).Warn("Readiness check degraded", "check", name, "error", err)
+				status.Checks[name] = "degraded"
+				return
+			}
+			if err != nil {
+				// The reason is logged rather than returned, since it can name internal hosts
+				logging.FromContext(r.Context()).Warn("Readiness check failed", "check", name, "error", err)
+				status.Checks[name] = "failed"
+				status.Status = "unavailable"
+				return
+			}
+			status.Checks[name] = "ok"
+		}(name, check)
+	}
+	wg.Wait()
+
+	status.Checks["shutdown"] = "ok"
+	if c.shuttingDown.Load() {
+		status.Checks["shutdown"] = "failed"
+		status.Status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
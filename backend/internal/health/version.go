@@ -0,0 +1,55 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+)
+
+// Set with -ldflags "-X ..." when the build has no VCS information, as in the Docker
+// image, whose build context doesn't include .git
+var (
+	Commit    string
+	BuildTime string
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`             // Module version, "(devel)" for local builds
+	Commit    string `json:"commit,omitempty"`    // VCS revision
+	Modified  bool   `json:"modified,omitempty"`  // Built from a working tree with uncommitted changes
+	BuildTime string `json:"buildTime,omitempty"` // Commit time from VCS, or the time set at build
+	GoVersion string `json:"goVersion"`
+}
+
+// ReadBuildInfo collects the build information embedded by the Go toolchain
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{Commit: Commit, BuildTime: BuildTime}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Version = build.Main.Version
+	info.GoVersion = build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// Version handles GET /version
+func Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReadBuildInfo())
+}
@@ -0,0 +1,173 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register the GIF decoder for image.Decode
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder for image.Decode
+)
+
+// jpegQuality balances file size and visible artefacts for photos
+const jpegQuality = 85
+
+// MaxPixels caps the size of images that are accepted. Decoding needs about four bytes
+// per pixel whatever the file size, so a small file can claim enormous dimensions.
+const MaxPixels = 40_000_000
+
+// ErrUnsupported is returned for formats that are stored untouched, such as animated GIFs
+var ErrUnsupported = errors.New("image format is not processed")
+
+// ErrTooLarge is returned for images with more than MaxPixels pixels
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Spec describes one generated variant of an uploaded image
+type Spec struct {
+	Name   string
+	Width  int
+	Height int  // Only used together with Crop
+	Crop   bool // Center-crop to exactly Width x Height instead of scaling to fit
+}
+
+// Responsive are the widths generated for every uploaded image. Widths
+// larger than the original are skipped; images are never upscaled.
+var Responsive = []Spec{
+	{Name: "w320", Width: 320},
+	{Name: "w640", Width: 640},
+	{Name: "w1280", Width: 1280},
+}
+
+// Thumbnail is a small preview that fits within a 200x200 box
+var Thumbnail = Spec{Name: "thumb", Width: 200, Height: 200}
+
+// Avatar are the square crops generated for profile pictures
+var Avatar = []Spec{
+	{Name: "avatar64", Width: 64, Height: 64, Crop: true},
+	{Name: "avatar256", Width: 256, Height: 256, Crop: true},
+}
+
+// Image is an encoded image ready to be stored
+type Image struct {
+	Name        string // Spec name, or "original"
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// Process decodes an uploaded image, turns it upright, and re-encodes the original
+// and every variant in specs. Re-encoding drops all metadata, including EXIF GPS
+// coordinates. GIFs return ErrUnsupported so animations survive untouched. The
+// dimensions are checked against MaxPixels before anything is decoded.
+func Process(data []byte, contentType string, specs []Spec) (Image, []Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return Image{}, nil, ErrTooLarge
+	}
+	if contentType == "image/gif" {
+		return Image{}, nil, ErrUnsupported
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, nil, err
+	}
+	if contentType == "image/jpeg" {
+		src = applyOrientation(src, jpegOrientation(data))
+	}
+
+	// PNGs and transparent WebPs stay lossless; everything else becomes JPEG
+	asPNG := contentType == "image/png" || !isOpaque(src)
+
+	original, err := encode("original", src, asPNG)
+	if err != nil {
+		return Image{}, nil, err
+	}
+
+	var variants []Image
+	for _, spec := range specs {
+		resized := resize(src, spec)
+		if resized == nil {
+			continue
+		}
+		variant, err := encode(spec.Name, resized, asPNG)
+		if err != nil {
+			return Image{}, nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return original, variants, nil
+}
+
+// Returns src scaled or cropped to spec, or nil if that would upscale it
+func resize(src image.Image, spec Spec) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	if spec.Crop {
+		// Take the largest centered square-ish region with the target aspect ratio
+		cropW, cropH := w, w*spec.Height/spec.Width
+		if cropH > h {
+			cropW, cropH = h*spec.Width/spec.Height, h
+		}
+		x0 := b.Min.X + (w-cropW)/2
+		y0 := b.Min.Y + (h-cropH)/2
+		region := image.Rect(x0, y0, x0+cropW, y0+cropH)
+
+		dw, dh := spec.Width, spec.Height
+		if cropW < dw {
+			dw, dh = cropW, cropH // Small source; crop without upscaling
+		}
+		return scale(src, region, dw, dh)
+	}
+
+	dw, dh := spec.Width, h*spec.Width/w
+	if spec.Height > 0 && dh > spec.Height {
+		dw, dh = w*spec.Height/h, spec.Height
+	}
+	if dw >= w || dw == 0 || dh == 0 {
+		return nil
+	}
+	return scale(src, b, dw, dh)
+}
+
+func scale(src image.Image, region image.Rectangle, width, height int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, region, draw.Src, nil)
+	return dst
+}
+
+func encode(name string, img image.Image, asPNG bool) (Image, error) {
+	var buf bytes.Buffer
+	out := Image{Name: name, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if asPNG {
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+		out.ContentType, out.Ext = "image/png", ".png"
+	} else {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+		out.ContentType, out.Ext = "image/jpeg", ".jpg"
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
+
+// Reports whether every pixel is fully opaque
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
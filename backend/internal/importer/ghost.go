@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+)
+
+type ghostExport struct {
+	DB []struct {
+		Data ghostData `json:"data"`
+	} `json:"db"`
+}
+
+type ghostData struct {
+	Posts []struct {
+		ID          string `json:"id"`
+		Title       string `json:"title"`
+		Slug        string `json:"slug"`
+		HTML        string `json:"html"`
+		Plaintext   string `json:"plaintext"`
+		Status      string `json:"status"`
+		Type        string `json:"type"` // "post" or "page" since Ghost 4
+		Page        bool   `json:"page"` // Older exports mark pages with this instead
+		PublishedAt string `json:"published_at"`
+		AuthorID    string `json:"author_id"`
+	} `json:"posts"`
+	Users []struct {
+		ID    string `json:"id"`
+		Slug  string `json:"slug"`
+		Email string `json:"email"`
+	} `json:"users"`
+	Tags []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Visibility string `json:"visibility"`
+	} `json:"tags"`
+	PostsTags []struct {
+		PostID    string `json:"post_id"`
+		TagID     string `json:"tag_id"`
+		SortOrder int    `json:"sort_order"`
+	} `json:"posts_tags"`
+	PostsAuthors []struct {
+		PostID    string `json:"post_id"`
+		AuthorID  string `json:"author_id"`
+		SortOrder int    `json:"sort_order"`
+	} `json:"posts_authors"`
+	Comments []struct {
+		ID        string `json:"id"`
+		PostID    string `json:"post_id"`
+		MemberID  string `json:"member_id"`
+		ParentID  string `json:"parent_id"`
+		Status    string `json:"status"`
+		HTML      string `json:"html"`
+		CreatedAt string `json:"created_at"`
+	} `json:"comments"`
+	Members []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"members"`
+}
+
+// ParseGhost reads a Ghost JSON export. Only published posts are kept, with their
+// primary author and public tags. Native comments are included when the export has them.
+func ParseGhost(r io.Reader) (*Site, error) {
+	var export ghostExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid Ghost export: %w", err)
+	}
+	if len(export.DB) == 0 {
+		return nil, fmt.Errorf("invalid Ghost export: no db section")
+	}
+	data := export.DB[0].Data
+
+	site := &Site{Source: "ghost"}
+	for _, u := range data.Users {
+		site.Authors = append(site.Authors, Author{Key: u.ID, Username: u.Slug, Email: u.Email})
+	}
+
+	// Internal tags start with # and are hidden from readers
+	tags := map[string]string{}
+	for _, t := range data.Tags {
+		if t.Visibility != "internal" {
+			tags[t.ID] = t.Name
+		}
+	}
+	postTags := map[string][]string{}
+	sort.SliceStable(data.PostsTags, func(i, j int) bool {
+		return data.PostsTags[i].SortOrder < data.PostsTags[j].SortOrder
+	})
+	for _, pt := range data.PostsTags {
+		if name, ok := tags[pt.TagID]; ok {
+			postTags[pt.PostID] = append(postTags[pt.PostID], name)
+		}
+	}
+
+	// The primary author is the one with the lowest sort order
+	primaryAuthor := map[string]string{}
+	primaryOrder := map[string]int{}
+	for _, pa := range data.PostsAuthors {
+		if order, ok := primaryOrder[pa.PostID]; !ok || pa.SortOrder < order {
+			primaryAuthor[pa.PostID] = pa.AuthorID
+			primaryOrder[pa.PostID] = pa.SortOrder
+		}
+	}
+
+	members := map[string]int{}
+	for i, m := range data.Members {
+		members[m.ID] = i
+	}
+	comments := map[string][]Comment{}
+	for _, c := range data.Comments {
+		if c.Status != "published" {
+			continue
+		}
+		comment := Comment{
+			Key:       c.ID,
+			ParentKey: c.ParentID,
+			HTML:      c.HTML,
+			CreatedAt: parseTime(c.CreatedAt),
+		}
+		if i, ok := members[c.MemberID]; ok {
+			comment.Author = data.Members[i].Name
+			comment.Email = data.Members[i].Email
+		}
+		comments[c.PostID] = append(comments[c.PostID], comment)
+	}
+
+	for _, p := range data.Posts {
+		if p.Status != "published" || p.Page || (p.Type != "" && p.Type != "post") {
+			site.Skipped++
+			continue
+		}
+
+		post := Post{
+			Key:         p.ID,
+			Title:       p.Title,
+			Slug:        p.Slug,
+			HTML:        p.HTML,
+			PublishedAt: parseTime(p.PublishedAt),
+			AuthorKey:   p.AuthorID,
+			Tags:        postTags[p.ID],
+			Comments:    comments[p.ID],
+		}
+		if author, ok := primaryAuthor[p.ID]; ok {
+			post.AuthorKey = author
+		}
+		if post.HTML == "" {
+			post.HTML = "<pre>" + html.EscapeString(p.Plaintext) + "</pre>" // Exports without rendered HTML still carry the text
+		}
+		site.Posts = append(site.Posts, post)
+	}
+	return site, nil
+}
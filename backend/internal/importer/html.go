@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	blankLinesPattern  = regexp.MustCompile(`\n{3,}`)
+	inlineSpacePattern = regexp.MustCompile(`[ \t\r\f\x{a0}]+`)
+)
+
+// HTMLToText converts post or comment HTML into the plain text with light Markdown
+// markup that posts are stored as. Paragraphs become blank-line separated, headings,
+// lists, quotes and code keep their Markdown form, and links keep their URL. Line
+// breaks in the source are kept because WordPress stores paragraphs as bare newlines.
+func HTMLToText(source string) string {
+	c := &converter{}
+	for _, n := range parseFragment(source) {
+		c.node(n)
+	}
+	return c.text()
+}
+
+// Returns the converted text with trailing spaces and extra blank lines removed
+func (c *converter) text() string {
+	lines := strings.Split(c.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	text := blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.Trim(text, "\n ")
+}
+
+func parseFragment(source string) []*html.Node {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(source), body)
+	if err != nil {
+		return []*html.Node{{Type: html.TextNode, Data: source}}
+	}
+	return nodes
+}
+
+type converter struct {
+	b     strings.Builder
+	pre   bool
+	lists []listState
+}
+
+type listState struct {
+	ordered bool
+	n       int
+}
+
+func (c *converter) write(s string) {
+	c.b.WriteString(s)
+}
+
+// Ends the current block with a blank line
+func (c *converter) block() {
+	c.write("\n\n")
+}
+
+func (c *converter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+func (c *converter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if !c.pre {
+			text = inlineSpacePattern.ReplaceAllString(text, " ")
+		}
+		c.write(text)
+		return
+	case html.ElementNode:
+	default:
+		c.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head:
+		return
+	case atom.Br:
+		c.write("\n")
+	case atom.Hr:
+		c.block()
+		c.write("---")
+		c.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Figure, atom.Table, atom.Tr:
+		c.block()
+		c.children(n)
+		c.block()
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		c.block()
+		c.write(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		c.children(n)
+		c.block()
+	case atom.Strong, atom.B:
+		c.wrap(n, "**")
+	case atom.Em, atom.I:
+		c.wrap(n, "*")
+	case atom.Code:
+		if c.pre {
+			c.children(n)
+		} else {
+			c.wrap(n, "`")
+		}
+	case atom.Pre:
+		c.block()
+		c.write("```\n")
+		c.pre = true
+		c.children(n)
+		c.pre = false
+		c.write("\n```")
+		c.block()
+	case atom.Blockquote:
+		// Quotes are converted on their own so every line of the result can be prefixed
+		quote := &converter{}
+		quote.children(n)
+		c.block()
+		for i, line := range strings.Split(quote.text(), "\n") {
+			if i > 0 {
+				c.write("\n")
+			}
+			c.write(strings.TrimRight("> "+line, " "))
+		}
+		c.block()
+	case atom.Ul, atom.Ol:
+		// Nested lists continue the enclosing one instead of starting a new block
+		nested := len(c.lists) > 0
+		if !nested {
+			c.block()
+		}
+		c.lists = append(c.lists, listState{ordered: n.DataAtom == atom.Ol})
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		if !nested {
+			c.block()
+		}
+	case atom.Li:
+		c.listItem(n)
+	case atom.A:
+		c.link(n)
+	case atom.Img:
+		if src := attr(n, "src"); src != "" {
+			c.write("![" + attr(n, "alt") + "](" + src + ")")
+		}
+	default:
+		c.children(n)
+	}
+}
+
+func (c *converter) wrap(n *html.Node, marker string) {
+	c.write(marker)
+	c.children(n)
+	c.write(marker)
+}
+
+func (c *converter) listItem(n *html.Node) {
+	marker := "- "
+	depth := len(c.lists)
+	if depth > 0 {
+		list := &c.lists[depth-1]
+		list.n++
+		if list.ordered {
+			marker = strconv.Itoa(list.n) + ". "
+		}
+	} else {
+		depth = 1
+	}
+	c.write("\n" + strings.Repeat("  ", depth-1) + marker)
+	c.children(n)
+}
+
+func (c *converter) link(n *html.Node) {
+	href := attr(n, "href")
+	if href == "" || strings.HasPrefix(href, "javascript:") {
+		c.children(n)
+		return
+	}
+	c.write("[")
+	c.children(n)
+	c.write("](" + href + ")")
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
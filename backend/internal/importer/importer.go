@@ -0,0 +1,313 @@
+// Package importer brings posts, their authors and comment threads over from other
+// blogging platforms. Each source format is parsed into a Site, which Importer then
+// writes through the repositories.
+package importer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Site is an export from another platform in a source-independent form
+type Site struct {
+	Source  string // Prefix for import keys, e.g. "wordpress"
+	Authors []Author
+	Posts   []Post
+	Skipped int // Drafts, pages and other entries that are not imported
+}
+
+// Author is a user who wrote posts on the source site
+type Author struct {
+	Key      string // Identifier in the source export
+	Username string
+	Email    string
+}
+
+// Post is a published post with its comments
+type Post struct {
+	Key         string
+	Title       string
+	Slug        string
+	HTML        string
+	PublishedAt time.Time
+	AuthorKey   string
+	Tags        []string
+	Comments    []Comment
+}
+
+// Comment is an approved comment; ParentKey is set for replies
+type Comment struct {
+	Key       string
+	ParentKey string
+	Author    string
+	Email     string
+	HTML      string
+	CreatedAt time.Time
+}
+
+// Report summarises what an import did, or would do in a dry run
+type Report struct {
+	DryRun           bool
+	UsersCreated     []string          // Usernames
+	Passwords        map[string]string // Temporary passwords of created users
+	UsersExisting    int               // Authors an earlier import created
+	PostsCreated     int
+	PostsExisting    int
+	PostsSkipped     int
+	CommentsCreated  int
+	CommentsExisting int
+	Warnings         []string
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Importer writes a Site into the database. Every imported post and comment records
+// where it came from, so running the same import again only adds what is new.
+type Importer struct {
+	users    repository.UserRepository
+	posts    repository.PostRepository
+	comments repository.CommentRepository
+}
+
+func New(users repository.UserRepository, posts repository.PostRepository, comments repository.CommentRepository) *Importer {
+	return &Importer{
+		users:    users,
+		posts:    posts,
+		comments: comments,
+	}
+}
+
+// Import writes site to the database. With dryRun set nothing is written and the
+// report describes what would have been imported.
+func (im *Importer) Import(ctx context.Context, site *Site, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Passwords: map[string]string{}, PostsSkipped: site.Skipped}
+
+	authors := map[string]model.User{}
+	byEmail := map[string]model.User{}
+	for _, author := range site.Authors {
+		user, err := im.author(ctx, site.Source, author, report)
+		if err != nil {
+			return report, err
+		}
+		authors[author.Key] = user
+		byEmail[strings.ToLower(user.Email)] = user
+	}
+
+	for _, post := range site.Posts {
+		author, ok := authors[post.AuthorKey]
+		if !ok {
+			report.warn("post %q: unknown author %q, skipped", post.Title, post.AuthorKey)
+			report.PostsSkipped++
+			continue
+		}
+		if err := im.post(ctx, site.Source, post, author, byEmail, report); err != nil {
+			return report, fmt.Errorf("post %q: %w", post.Title, err)
+		}
+	}
+	return report, nil
+}
+
+// Returns the user an earlier import created for the author, creating one if needed.
+// Local accounts are never matched by username, since an author called "admin" on the
+// source site is a different person from the local admin; a taken username gets a
+// suffix instead.
+func (im *Importer) author(ctx context.Context, source string, author Author, report *Report) (model.User, error) {
+	importKey := source + ":" + author.Key
+	existing, err := im.users.GetUserByImportSource(ctx, importKey)
+	if err == nil {
+		report.UsersExisting++
+		return existing, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return model.User{}, err
+	}
+
+	wanted := strings.TrimSpace(author.Username)
+	username, err := im.freeUsername(ctx, wanted)
+	if err != nil {
+		return model.User{}, err
+	}
+	if username != wanted {
+		report.warn("author %s: the username belongs to an existing account; creating %s instead", wanted, username)
+	}
+
+	email := author.Email
+	if email == "" {
+		email = username + "@imported.invalid"
+		report.warn("author %s has no email address; using %s", username, email)
+	}
+	user := model.User{
+		ID:           primitive.NewObjectID(),
+		Username:     username,
+		Email:        email,
+		Author:       true,
+		ImportSource: importKey,
+	}
+	report.UsersCreated = append(report.UsersCreated, username)
+	if report.DryRun {
+		return user, nil
+	}
+
+	// Imported authors get a random password to hand over, since the source's hashes can't be reused
+	if user.Password, err = randomPassword(); err != nil {
+		return model.User{}, err
+	}
+	err = im.users.CreateUser(ctx, user)
+	if errors.Is(err, repository.ErrEmailTaken) {
+		// As with usernames, the local account with this email isn't assumed to be the author
+		user.Email = username + "@imported.invalid"
+		report.warn("author %s: %s belongs to an existing account; using %s", username, email, user.Email)
+		err = im.users.CreateUser(ctx, user)
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("creating user %s: %w", username, err)
+	}
+	report.Passwords[username] = user.Password
+	return user, nil
+}
+
+// Returns username, or username with the lowest numeric suffix no account has
+func (im *Importer) freeUsername(ctx context.Context, username string) (string, error) {
+	for n := 1; ; n++ {
+		candidate := username
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", username, n)
+		}
+		_, err := im.users.GetUserByUsername(ctx, candidate)
+		if err != nil && err.Error() == "user not found" {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (im *Importer) post(ctx context.Context, source string, post Post, author model.User, byEmail map[string]model.User, report *Report) error {
+	importKey := source + ":" + post.Key
+	existing, err := im.posts.GetPosts(ctx, bson.M{"importSource": importKey}, 1, 0)
+	if err != nil {
+		return err
+	}
+
+	var stored model.Post
+	known := map[string]primitive.ObjectID{} // Comment import keys already in the database
+	if len(existing) > 0 {
+		report.PostsExisting++
+		stored = existing[0]
+		comments, err := im.comments.GetCommentsByPost(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			if comment.ImportSource != "" {
+				known[comment.ImportSource] = comment.ID
+			}
+		}
+	} else {
+		report.PostsCreated++
+		stored = model.Post{
+			ID:             primitive.NewObjectID(),
+			Title:          post.Title,
+			Slug:           post.Slug,
+			Content:        HTMLToText(post.HTML),
+			PublishedAt:    post.PublishedAt,
+			AuthorID:       author.ID,
+			AuthorUsername: author.Username,
+			Tags:           model.NormalizeTags(post.Tags),
+			ImportSource:   importKey,
+		}
+		if stored.PublishedAt.IsZero() {
+			stored.PublishedAt = time.Now()
+		}
+		if !report.DryRun {
+			if err := im.posts.CreatePost(ctx, &stored); err != nil {
+				return err
+			}
+		}
+	}
+
+	return im.postComments(ctx, source, post.Comments, stored.ID, known, byEmail, report)
+}
+
+// Creates the comments not yet imported, parents before replies
+func (im *Importer) postComments(ctx context.Context, source string, comments []Comment, postID primitive.ObjectID, known map[string]primitive.ObjectID, byEmail map[string]model.User, report *Report) error {
+	sorted := append([]Comment(nil), comments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	for _, comment := range sorted {
+		importKey := source + ":comment:" + comment.Key
+		if _, ok := known[importKey]; ok {
+			report.CommentsExisting++
+			continue
+		}
+
+		stored := model.Comment{
+			ID:           primitive.NewObjectID(),
+			PostID:       postID,
+			Author:       comment.Author,
+			Email:        comment.Email,
+			Content:      HTMLToText(comment.HTML),
+			CreatedAt:    comment.CreatedAt,
+			ImportSource: importKey,
+		}
+		if stored.Author == "" {
+			stored.Author = "anonymous"
+		}
+		// Comments by people who also wrote posts are linked to their account
+		if user, ok := byEmail[strings.ToLower(comment.Email)]; ok && comment.Email != "" {
+			stored.AuthorID = user.ID
+			stored.Author = user.Username
+		}
+		if comment.ParentKey != "" {
+			if parentID, ok := known[source+":comment:"+comment.ParentKey]; ok {
+				stored.ParentID = &parentID
+			} else {
+				report.warn("comment %s replies to unknown comment %s; imported as a top-level comment", comment.Key, comment.ParentKey)
+			}
+		}
+
+		if !report.DryRun {
+			if err := im.comments.CreateComment(ctx, stored); err != nil {
+				return err
+			}
+		}
+		known[importKey] = stored.ID
+		report.CommentsCreated++
+	}
+	return nil
+}
+
+func randomPassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Parses the date formats used by export files, returning the zero time for an empty or unknown value
+func parseTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", time.RFC1123Z, time.RFC1123} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WXR elements are matched by local name because the wp: namespace URI changes between
+// export versions (1.0 to 1.2). content:encoded is the exception, as excerpt:encoded shares its name.
+type wxr struct {
+	Channel struct {
+		Authors []wxrAuthor `xml:"author"`
+		Items   []wxrItem   `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrAuthor struct {
+	Login string `xml:"author_login"`
+	Email string `xml:"author_email"`
+}
+
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	Creator    string        `xml:"creator"`
+	Content    string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID     string        `xml:"post_id"`
+	PostDate   string        `xml:"post_date_gmt"`
+	PostName   string        `xml:"post_name"`
+	Status     string        `xml:"status"`
+	PostType   string        `xml:"post_type"`
+	Categories []wxrCategory `xml:"category"`
+	Comments   []wxrComment  `xml:"comment"`
+}
+
+type wxrCategory struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+type wxrComment struct {
+	ID          string `xml:"comment_id"`
+	Author      string `xml:"comment_author"`
+	AuthorEmail string `xml:"comment_author_email"`
+	Date        string `xml:"comment_date_gmt"`
+	Content     string `xml:"comment_content"`
+	Approved    string `xml:"comment_approved"`
+	Type        string `xml:"comment_type"`
+	Parent      string `xml:"comment_parent"`
+}
+
+// ParseWordPress reads a WordPress WXR export. Only published posts and approved
+// comments are kept; pingbacks, pages, attachments and drafts are counted as skipped.
+func ParseWordPress(r io.Reader) (*Site, error) {
+	var doc wxr
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false // Exports from old plugins often contain stray HTML entities
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid WXR file: %w", err)
+	}
+
+	site := &Site{Source: "wordpress"}
+	for _, a := range doc.Channel.Authors {
+		site.Authors = append(site.Authors, Author{Key: a.Login, Username: a.Login, Email: a.Email})
+	}
+
+	for _, item := range doc.Channel.Items {
+		if item.PostType != "post" || item.Status != "publish" {
+			site.Skipped++
+			continue
+		}
+
+		post := Post{
+			Key:         item.PostID,
+			Title:       strings.TrimSpace(item.Title),
+			Slug:        item.PostName,
+			HTML:        item.Content,
+			PublishedAt: parseTime(item.PostDate),
+			AuthorKey:   item.Creator,
+		}
+		for _, category := range item.Categories {
+			// Every uncategorised post is in "uncategorized", which makes a useless tag
+			if category.Domain == "category" && category.Nicename == "uncategorized" {
+				continue
+			}
+			if category.Domain == "post_tag" || category.Domain == "category" {
+				post.Tags = append(post.Tags, category.Name)
+			}
+		}
+		for _, c := range item.Comments {
+			if c.Approved != "1" || (c.Type != "" && c.Type != "comment") {
+				continue
+			}
+			comment := Comment{
+				Key:       c.ID,
+				Author:    c.Author,
+				Email:     c.AuthorEmail,
+				HTML:      c.Content,
+				CreatedAt: parseTime(c.Date),
+			}
+			if c.Parent != "" && c.Parent != "0" {
+				comment.ParentKey = c.Parent
+			}
+			post.Comments = append(post.Comments, comment)
+		}
+		site.Posts = append(site.Posts, post)
+	}
+
+	// Authors are only listed in WXR 1.1 and later; fall back to the post creators
+	if len(site.Authors) == 0 {
+		seen := map[string]bool{}
+		for _, post := range site.Posts {
+			if !seen[post.AuthorKey] {
+				seen[post.AuthorKey] = true
+				site.Authors = append(site.Authors, Author{Key: post.AuthorKey, Username: post.AuthorKey})
+			}
+		}
+	}
+	return site, nil
+}
@@ -0,0 +1,81 @@
+// Package logging configures the application's structured logger and carries a
+// request-scoped logger through contexts, so every line logged while handling a request
+// can be tied back to it by its request ID.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// Attribute keys whose values never belong in a log, compared case-insensitively
+var sensitiveKeys = map[string]bool{
+	"password":       true,
+	"hashedpassword": true,
+	"token":          true,
+	"secret":         true,
+	"authorization":  true,
+	"cookie":         true,
+	"set-cookie":     true,
+	"x-csrf-token":   true,
+	"email":          true,
+}
+
+// Options configure the logger, usually from LOG_LEVEL and LOG_FORMAT
+type Options struct {
+	Level  string // debug, info, warn or error; info if empty
+	Format string // json or text; text if empty
+}
+
+// New returns a logger writing to w with sensitive attributes redacted
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or text", opts.Format)
+	}
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+type contextKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger if there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger adds args to every record
+func With(ctx context.Context, args ...interface{}) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,100 @@
+// Package markdown reads and writes posts as Markdown files with YAML front matter:
+//
+//	---
+//	title: Hello world
+//	date: 2024-05-01T09:30:00Z
+//	tags: [go, mongodb]
+//	slug: hello-world
+//	author: alice
+//	---
+//
+//	Post content...
+//
+// The body is stored as the post's content unchanged, so a post written by Render
+// and read back by Parse comes out the same.
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+)
+
+const delimiter = "---"
+
+// ErrNoFrontMatter is returned for files that don't start with a front matter block
+var ErrNoFrontMatter = errors.New("file has no front matter")
+
+// FrontMatter is the metadata block at the top of a post file
+type FrontMatter struct {
+	Title  string    `yaml:"title"`
+	Date   time.Time `yaml:"date,omitempty"`
+	Tags   []string  `yaml:"tags,omitempty,flow"`
+	Slug   string    `yaml:"slug,omitempty"`
+	Author string    `yaml:"author,omitempty"` // Username of the post's author
+}
+
+// Document is a parsed post file
+type Document struct {
+	FrontMatter
+	Body string
+}
+
+// Parse splits a post file into its front matter and body
+func Parse(data []byte) (Document, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	if !strings.HasPrefix(text, delimiter+"\n") {
+		return Document{}, ErrNoFrontMatter
+	}
+	rest := text[len(delimiter)+1:]
+
+	var header, body string
+	switch end := strings.Index(rest, "\n"+delimiter+"\n"); {
+	case strings.HasPrefix(rest, delimiter+"\n") || rest == delimiter: // Empty front matter
+		body = strings.TrimPrefix(rest, delimiter)
+	case end >= 0:
+		header, body = rest[:end], rest[end+len(delimiter)+2:]
+	case strings.HasSuffix(rest, "\n"+delimiter): // Front matter only
+		header = strings.TrimSuffix(rest, "\n"+delimiter)
+	default:
+		return Document{}, fmt.Errorf("front matter is not closed with %q", delimiter)
+	}
+
+	var doc Document
+	if err := yaml.Unmarshal([]byte(header), &doc.FrontMatter); err != nil {
+		return Document{}, fmt.Errorf("invalid front matter: %w", err)
+	}
+	doc.Body = strings.Trim(body, "\n")
+	if doc.Title == "" {
+		return Document{}, fmt.Errorf("front matter has no title")
+	}
+	return doc, nil
+}
+
+// Render writes a post as a Markdown file with front matter
+func Render(post model.Post) ([]byte, error) {
+	header, err := yaml.Marshal(FrontMatter{
+		Title:  post.Title,
+		Date:   post.PublishedAt.UTC(),
+		Tags:   post.Tags,
+		Slug:   post.Slug,
+		Author: post.AuthorUsername,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(delimiter + "\n")
+	buf.Write(header)
+	buf.WriteString(delimiter + "\n\n")
+	buf.WriteString(strings.Trim(post.Content, "\n"))
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Access controls who may scrape /metrics. A request is let through if it carries the
+// token as a Bearer token or comes from an allowed address. With neither configured,
+// every request is refused: behind a reverse proxy on the same host, all requests
+// would otherwise appear to come from loopback.
+type Access struct {
+	Token string
+	Allow []netip.Prefix
+}
+
+// ParseAllowList parses a comma-separated list of IP addresses and CIDR ranges, as in METRICS_ALLOW
+func ParseAllowList(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid metrics allow-list entry %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics allow-list entry %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Configured reports whether anyone may scrape the metrics
+func (a Access) Configured() bool {
+	return a.Token != "" || len(a.Allow) > 0
+}
+
+// Handler serves the registered metrics to clients permitted by access
+func Handler(access Access) http.Handler {
+	metrics := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !access.permits(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+func (a Access) permits(r *http.Request) bool {
+	if a.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1 {
+			return true
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range a.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+// Package migrate applies versioned changes to the documents in the database, such as
+// backfilling a field older documents lack. Applied versions are recorded in the
+// migrations collection, and a lock document keeps instances that start together from
+// running the same migration twice.
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+)
+
+// Collections holding the applied migrations and the lock
+const (
+	migrationsCollection = "migrations"
+	locksCollection      = "locks"
+	lockID               = "migrations"
+)
+
+// Migration is one versioned change. Up and Down should be safe to run again after a
+// partial failure, since a migration is only recorded once Up has returned.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error // nil if it can't be reverted
+}
+
+// Status is a migration's state in the database
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time // Zero if pending
+	Unknown   bool      // Applied by a newer build that declares it
+}
+
+// Recorded in the migrations collection for each applied migration
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// ErrIrreversible is returned by Down for a migration without a Down function
+var ErrIrreversible = errors.New("migration can't be reverted")
+
+// Migrator runs migrations against a database
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	owner      string
+	lease      time.Duration // How long the lock is held without being renewed
+	retry      time.Duration // How often a held lock is tried again
+}
+
+// New returns a Migrator for migrations, which must have distinct positive versions
+func New(db *mongo.Database, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has version %d, want a positive version", m.Name, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", sorted[i-1].Name, m.Name, m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up function", m.Version)
+		}
+	}
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		owner:      newOwner(),
+		lease:      time.Minute,
+		retry:      time.Second,
+	}, nil
+}
+
+// Latest returns the highest declared version, or 0 if there are none
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists every declared migration and any applied one this build doesn't know, by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if rec, ok := applied[migration.Version]; ok {
+			status.AppliedAt = rec.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, rec := range applied {
+		statuses = append(statuses, Status{Version: rec.Version, Name: rec.Name, AppliedAt: rec.AppliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Up applies pending migrations up to and including version target, in order, and
+// returns how many were applied. A target of 0 means the latest version.
+func (m *Migrator) Up(ctx context.Context, target int) (int, error) {
+	if target == 0 {
+		target = m.Latest()
+	}
+	count := 0
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, migration, "up"); err != nil {
+				return err
+			}
+			rec := record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}
+			if _, err := m.db.Collection(migrationsCollection).InsertOne(ctx, rec); err != nil {
+				return fmt.Errorf("recording migration %d: %w", migration.Version, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts applied migrations above version target, newest first, and returns how
+// many were reverted. Down(ctx, 0) reverts every migration.
+func (m *Migrator) Down(ctx context.Context, target int) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		declared := make(map[int]Migration, len(m.migrations))
+		for _, migration := range m.migrations {
+			declared[migration.Version] = migration
+		}
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			if version > target {
+				versions = append(versions, version)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		for _, version := range versions {
+			migration, ok := declared[version]
+			if !ok {
+				return fmt.Errorf("migration %d (%s) was applied by a newer build", version, applied[version].Name)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d (%s): %w", version, migration.Name, ErrIrreversible)
+			}
+			if err := m.run(ctx, migration, "down"); err != nil {
+				return err
+			}
+			if _, err := m.db.Collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": version}); err != nil {
+				return fmt.Errorf("unrecording migration %d: %w", version, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Runs one direction of migration, logging how long it took
+func (m *Migrator) run(ctx context.Context, migration Migration, direction string) error {
+	logger := logging.FromContext(ctx).With("version", migration.Version, "migration", migration.Name, "direction", direction)
+	logger.Info("Running migration")
+	start := time.Now()
+	fn := migration.Up
+	if direction == "down" {
+		fn = migration.Down
+	}
+	if err := fn(ctx, m.db); err != nil {
+		return fmt.Errorf("migration %d (%s) %s: %w", migration.Version, migration.Name, direction, err)
+	}
+	logger.Info("Finished migration", "duration", time.Since(start))
+	return nil
+}
+
+// Returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cur, err := m.db.Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return applied, nil
+}
+
+// Runs fn while holding the migrations lock, waiting for another instance to release
+// it first. The lock is a lease renewed while fn runs, so a crashed instance's lock
+// expires instead of blocking migrations forever; fn's context is cancelled if the
+// lease is lost.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	for {
+		ok, err := m.acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("acquiring the migrations lock: %w", err)
+		}
+		if ok {
+			break
+		}
+		logging.FromContext(ctx).Info("Waiting for another instance to finish migrating")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.retry):
+		}
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(m.lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-fnCtx.Done():
+				return
+			case <-ticker.C:
+				if err := m.renew(fnCtx); err != nil {
+					cancel(fmt.Errorf("lost the migrations lock: %w", err))
+					return
+				}
+			}
+		}
+	}()
+
+	err := fn(fnCtx)
+	if err != nil && fnCtx.Err() != nil && ctx.Err() == nil {
+		err = context.Cause(fnCtx)
+	}
+	cancel(nil)
+	<-renewed
+
+	// Released even if ctx is done, so other instances don't wait for the lease to run out
+	releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancelRelease()
+	if _, releaseErr := m.db.Collection(locksCollection).DeleteOne(releaseCtx, bson.M{"_id": lockID, "owner": m.owner}); releaseErr != nil {
+		logging.FromContext(ctx).Warn("Failed to release the migrations lock", "error", releaseErr)
+	}
+	return err
+}
+
+// Takes the lock if it is free or its lease has run out. While another instance holds
+// it the filter doesn't match, so the upsert's insert fails on the duplicate _id.
+func (m *Migrator) acquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	filter := bson.M{"_id": lockID, "expiresAt": bson.M{"$lt": now}}
+	update := bson.M{"$set": bson.M{"owner": m.owner, "acquiredAt": now, "expiresAt": now.Add(m.lease)}}
+	_, err := m.db.Collection(locksCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Extends the lease on a lock this instance holds
+func (m *Migrator) renew(ctx context.Context) error {
+	filter := bson.M{"_id": lockID, "owner": m.owner}
+	update := bson.M{"$set": bson.M{"expiresAt": time.Now().Add(m.lease)}}
+	result, err := m.db.Collection(locksCollection).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("held by another instance")
+	}
+	return nil
+}
+
+// Identifies this process as the lock's holder
+func newOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
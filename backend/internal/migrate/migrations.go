@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Migrations returns the blog's migrations. Add new ones at the end with the next
+// version; never renumber or edit one that has shipped.
+func Migrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "comment-updated-at", Up: commentUpdatedAtUp, Down: commentUpdatedAtDown},
+		{Version: 2, Name: "post-slugs", Up: postSlugsUp},
+	}
+}
+
+// Comments created before updatedAt was part of the model only have it once edited;
+// the rest get their creation time, as new comments do
+func commentUpdatedAtUp(ctx context.Context, db *mongo.Database) error {
+	filter := bson.M{"updatedAt": bson.M{"$exists": false}}
+	update := bson.A{bson.M{"$set": bson.M{"updatedAt": "$createdAt"}}}
+	_, err := db.Collection("comments").UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Removes updatedAt from comments that were never edited
+func commentUpdatedAtDown(ctx context.Context, db *mongo.Database) error {
+	filter := bson.M{"$expr": bson.M{"$eq": bson.A{"$updatedAt", "$createdAt"}}}
+	update := bson.M{"$unset": bson.M{"updatedAt": ""}}
+	_, err := db.Collection("comments").UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Posts from before slugs only got one when next edited. Each gets a unique slug from
+// its title, as new posts do; nothing else about the post changes. There is no Down,
+// since the slugs may already be in shared links.
+func postSlugsUp(ctx context.Context, db *mongo.Database) error {
+	posts := db.Collection("posts")
+	filter := bson.M{"slug": bson.M{"$in": bson.A{nil, ""}}}
+	cur, err := posts.Find(ctx, filter, options.Find().SetProjection(bson.M{"title": 1}))
+	if err != nil {
+		return err
+	}
+	var missing []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Title string             `bson:"title"`
+	}
+	if err := cur.All(ctx, &missing); err != nil {
+		return err
+	}
+
+	for _, post := range missing {
+		base := postSlugsMake(post.Title)
+		for n := 1; ; n++ {
+			candidate := base
+			if n > 1 {
+				candidate = base + "-" + strconv.Itoa(n)
+			}
+			taken, err := posts.CountDocuments(ctx, bson.M{
+				"_id": bson.M{"$ne": post.ID},
+				"$or": bson.A{bson.M{"slug": candidate}, bson.M{"previousSlugs": candidate}},
+			})
+			if err != nil {
+				return err
+			}
+			if taken > 0 {
+				continue
+			}
+			if _, err := posts.UpdateOne(ctx, bson.M{"_id": post.ID}, bson.M{"$set": bson.M{"slug": candidate}}); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// A copy of slug.Make as it was when post-slugs shipped, so later changes to slugs
+// don't change what the migration does
+func postSlugsMake(title string) string {
+	const maxLength = 80
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), title)
+	if err != nil {
+		stripped = title
+	}
+
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(stripped) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+
+	s := b.String()
+	if len(s) > maxLength {
+		s = s[:maxLength]
+		if i := strings.LastIndexByte(s, '-'); i > maxLength/2 {
+			s = s[:i]
+		}
+		s = strings.TrimRight(s, "-")
+	}
+	if s == "" {
+		return "post"
+	}
+	return s
+}
@@ -9,9 +9,25 @@ import (
 type Comment struct {
 	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	PostID primitive.ObjectID `bson:"postId" json:"postId"`
+	ParentID *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"` // Comment this one replies to
 	Author string `bson:"author" json:"author" binding:"required"`
 	AuthorID primitive.ObjectID `bson:"authorId" json:"authorId"`
-	Email string `bson:"email,omitempty" json:"email,omitempty"`
+	Email string `bson:"email,omitempty" json:"-"` // Only shown to the author, through OwnerView
 	Content string `bson:"content" json:"content" binding:"required"`
 	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
-}
\ No newline at end of file
+	UpdatedAt time.Time `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+	ReactionCounts map[string]int64 `bson:"reactionCounts,omitempty" json:"reactionCounts,omitempty"`
+	MyReactions []string `bson:"-" json:"myReactions,omitempty"`
+	ImportSource string `bson:"importSource,omitempty" json:"-"` // e.g. "wordpress:42" for comments brought over from another blog
+}
+
+// OwnComment is a comment as its own author sees it, with the email it was left under
+type OwnComment struct {
+	Comment
+	Email string `json:"email,omitempty"`
+}
+
+// OwnerView returns the comment with the fields only its author may see
+func (c Comment) OwnerView() OwnComment {
+	return OwnComment{Comment: c, Email: c.Email}
+}
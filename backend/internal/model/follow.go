@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Follow struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FollowerID       primitive.ObjectID `bson:"followerId" json:"followerId"`
+	FollowerUsername string             `bson:"followerUsername" json:"followerUsername"`
+	FolloweeID       primitive.ObjectID `bson:"followeeId" json:"followeeId"`
+	FolloweeUsername string             `bson:"followeeUsername" json:"followeeUsername"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Media struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerID     primitive.ObjectID `bson:"ownerId" json:"ownerId"`
+	Key         string             `bson:"key" json:"-"`
+	URL         string             `bson:"url" json:"url"`
+	Filename    string             `bson:"filename" json:"filename"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"`
+	Width       int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int                `bson:"height,omitempty" json:"height,omitempty"`
+	Variants    []MediaVariant     `bson:"variants,omitempty" json:"variants,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// MediaVariant is a resized or cropped copy generated from an uploaded image
+type MediaVariant struct {
+	Name   string `bson:"name" json:"name"`
+	Key    string `bson:"key" json:"-"`
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+	Size   int64  `bson:"size" json:"size"`
+}
+
+// VariantURL returns the URL of the first variant found among names, falling back to the original
+func (m Media) VariantURL(names ...string) string {
+	for _, name := range names {
+		for _, variant := range m.Variants {
+			if variant.Name == name {
+				return variant.URL
+			}
+		}
+	}
+	return m.URL
+}
+
+// IsImage reports whether the media is an image
+func (m Media) IsImage() bool {
+	return strings.HasPrefix(m.ContentType, "image/")
+}
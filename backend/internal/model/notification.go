@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of notification a user can receive
+const (
+	NotificationComment  = "comment"
+	NotificationMention  = "mention"
+	NotificationReaction = "reaction"
+)
+
+type Notification struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Type          string             `bson:"type" json:"type"`
+	ActorID       primitive.ObjectID `bson:"actorId" json:"actorId"`
+	ActorUsername string             `bson:"actorUsername" json:"actorUsername"`
+	PostID        primitive.ObjectID `bson:"postId,omitempty" json:"postId,omitempty"`
+	CommentID     primitive.ObjectID `bson:"commentId,omitempty" json:"commentId,omitempty"`
+	ReactionType  string             `bson:"reactionType,omitempty" json:"reactionType,omitempty"`
+	Read          bool               `bson:"read" json:"read"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// NotificationPreferences controls which notifications a user receives
+type NotificationPreferences struct {
+	Comments  bool `bson:"comments" json:"comments"`
+	Mentions  bool `bson:"mentions" json:"mentions"`
+	Reactions bool `bson:"reactions" json:"reactions"`
+}
+
+// DefaultNotificationPreferences are used for users who never changed their settings
+var DefaultNotificationPreferences = NotificationPreferences{
+	Comments:  true,
+	Mentions:  true,
+	Reactions: true,
+}
+
+// Allows reports whether the preferences allow a notification of the given type
+func (p NotificationPreferences) Allows(notificationType string) bool {
+	switch notificationType {
+	case NotificationComment:
+		return p.Comments
+	case NotificationMention:
+		return p.Mentions
+	case NotificationReaction:
+		return p.Reactions
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -9,17 +10,51 @@ import (
 type Post struct {
 	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title string `bson:"title" json:"title" binding:"required"`
+	Slug string `bson:"slug,omitempty" json:"slug,omitempty"`
+	PreviousSlugs []string `bson:"previousSlugs,omitempty" json:"-"`
 	Content string `bson:"content" json:"content" binding:"required"`
 	PublishedAt time.Time `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
 	AuthorID primitive.ObjectID `bson:"authorId" json:"authorId"`
 	AuthorUsername string `bson:"authorUsername" json:"authorUsername"`
+	Tags []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	CoverMediaID *primitive.ObjectID `bson:"coverMediaId,omitempty" json:"coverMediaId,omitempty"`
+	CoverImage string `bson:"coverImage,omitempty" json:"coverImage,omitempty"`
+	ReactionCounts map[string]int64 `bson:"reactionCounts,omitempty" json:"reactionCounts,omitempty"`
+	MyReactions []string `bson:"-" json:"myReactions,omitempty"`
+	ImportSource string `bson:"importSource,omitempty" json:"-"` // e.g. "ghost:5f1a..." for posts brought over from another blog
 }
 
 type PostResponse struct {
-    ID             string    `json:"id"`
-    Title          string    `json:"title"`
-    Content        string    `json:"content"`
-    PublishedAt    time.Time `json:"publishedAt"`
-    AuthorID       string    `json:"authorId"`
-    AuthorUsername string    `json:"authorUsername"`
+    ID             string           `json:"id"`
+    Title          string           `json:"title"`
+    Slug           string           `json:"slug"`
+    Content        string           `json:"content"`
+    PublishedAt    time.Time        `json:"publishedAt"`
+    AuthorID       string           `json:"authorId"`
+    AuthorUsername string           `json:"authorUsername"`
+    Tags           []string         `json:"tags,omitempty"`
+    CoverMediaID   string           `json:"coverMediaId,omitempty"`
+    CoverImage     string           `json:"coverImage,omitempty"`
+    ReactionCounts map[string]int64 `json:"reactionCounts,omitempty"`
+    MyReactions    []string         `json:"myReactions,omitempty"`
+}
+
+// NormalizeTag lowercases a tag and trims surrounding whitespace
+func NormalizeTag(tag string) string {
+    return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags normalizes every tag and drops empty and duplicate entries
+func NormalizeTags(tags []string) []string {
+    var normalized []string
+    seen := make(map[string]bool)
+    for _, tag := range tags {
+        tag = NormalizeTag(tag)
+        if tag == "" || seen[tag] {
+            continue
+        }
+        seen[tag] = true
+        normalized = append(normalized, tag)
+    }
+    return normalized
 }
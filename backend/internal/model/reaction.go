@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Targets a reaction can be attached to
+const (
+	ReactionTargetPost    = "post"
+	ReactionTargetComment = "comment"
+)
+
+// ReactionTypes is the set of reactions a user can leave on a post or comment
+var ReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "celebrate"}
+
+// IsValidReactionType reports whether t is one of the supported reaction types
+func IsValidReactionType(t string) bool {
+	for _, rt := range ReactionTypes {
+		if rt == t {
+			return true
+		}
+	}
+	return false
+}
+
+type Reaction struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TargetType string             `bson:"targetType" json:"targetType"`
+	TargetID   primitive.ObjectID `bson:"targetId" json:"targetId"`
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	Username   string             `bson:"username" json:"username"`
+	Type       string             `bson:"type" json:"type"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
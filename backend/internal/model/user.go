@@ -16,5 +16,42 @@ type User struct {
 	Author bool `bson:"author" json:"author"`
 	Bio            string             `bson:"bio,omitempty" json:"bio,omitempty"`
     ProfilePicURL  string             `bson:"profilePicUrl,omitempty" json:"profilePicUrl,omitempty"`
+    ProfilePicMediaID *primitive.ObjectID `bson:"profilePicMediaId,omitempty" json:"profilePicMediaId,omitempty"`
     UpdatedAt      time.Time          `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
-}
\ No newline at end of file
+    NotificationPrefs *NotificationPreferences `bson:"notificationPrefs,omitempty" json:"notificationPrefs,omitempty"`
+    DeletionRequestedAt *time.Time `bson:"deletionRequestedAt,omitempty" json:"deletionRequestedAt,omitempty"`
+    ImportSource string `bson:"importSource,omitempty" json:"-"` // e.g. "wordpress:admin" for authors an import created
+}
+
+// Name shown in place of the author of comments left by a deleted account
+const DeletedUsername = "deleted user"
+
+// NotificationPreferences returns the user's notification settings, or the defaults if none were saved
+func (u User) NotificationPreferences() NotificationPreferences {
+    if u.NotificationPrefs == nil {
+        return DefaultNotificationPreferences
+    }
+    return *u.NotificationPrefs
+}
+
+// PublicProfile is the part of a user that anyone, including logged-out visitors, may see
+type PublicProfile struct {
+    ID            primitive.ObjectID `json:"id"`
+    Username      string             `json:"username"`
+    Author        bool               `json:"author"`
+    Bio           string             `json:"bio,omitempty"`
+    ProfilePicURL string             `json:"profilePicUrl,omitempty"`
+    CreatedAt     time.Time          `json:"createdAt"`
+}
+
+// PublicProfile returns the user's publicly visible fields
+func (u User) PublicProfile() PublicProfile {
+    return PublicProfile{
+        ID:            u.ID,
+        Username:      u.Username,
+        Author:        u.Author,
+        Bio:           u.Bio,
+        ProfilePicURL: u.ProfilePicURL,
+        CreatedAt:     u.CreatedAt,
+    }
+}
@@ -0,0 +1,173 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+const (
+	// historySize is how many recent events are kept for Last-Event-ID resume, across all topics
+	historySize = 1000
+	// bufferSize is how many undelivered events a subscriber may fall behind before it is dropped
+	bufferSize = 64
+)
+
+// Event is a single message published to a topic
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+// Returns the topic carrying comment events for a post
+func PostTopic(postID string) string {
+	return "post:" + postID
+}
+
+// Returns the topic carrying notifications for a user
+func UserTopic(userID string) string {
+	return "user:" + userID
+}
+
+// Hub is an in-process publish/subscribe broker for server-sent events.
+// Event IDs increase monotonically for the lifetime of the process.
+type Hub struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history []Event
+	subs    map[string]map[*Subscription]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives events for a fixed set of topics until it is closed.
+// C is closed when the subscription ends, either by Close or because the subscriber fell too far behind.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	topics []string
+	hub    *Hub
+	closed bool
+}
+
+// Publish encodes payload as JSON and delivers it to every subscriber of topic
+func (h *Hub) Publish(topic, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Topic: topic, Type: eventType, Data: data}
+	h.history = append(h.history, event)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+
+	for sub := range h.subs[topic] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Too slow; drop it so the client reconnects and resumes from history
+			h.unsubscribe(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers for events on topics. Events newer than lastEventID that are still in
+// history are returned so the caller can replay them before reading from the subscription.
+func (h *Hub) Subscribe(topics []string, lastEventID uint64) (*Subscription, []Event) {
+	ch := make(chan Event, bufferSize)
+	sub := &Subscription{C: ch, ch: ch, topics: topics, hub: h}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		sub.closed = true
+		close(sub.ch)
+		return sub, nil
+	}
+
+	var missed []Event
+	// An ID from before a restart can be ahead of ours; there is nothing meaningful to replay then
+	if lastEventID > 0 && lastEventID <= h.lastID {
+		wanted := make(map[string]bool, len(topics))
+		for _, topic := range topics {
+			wanted[topic] = true
+		}
+		for _, event := range h.history {
+			if event.ID > lastEventID && wanted[event.Topic] {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	for _, topic := range topics {
+		if h.subs[topic] == nil {
+			h.subs[topic] = make(map[*Subscription]struct{})
+		}
+		h.subs[topic][sub] = struct{}{}
+	}
+	return sub, missed
+}
+
+// Returns the number of open subscriptions
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := make(map[*Subscription]struct{})
+	for _, subs := range h.subs {
+		for sub := range subs {
+			seen[sub] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// Close ends every subscription and makes later ones end immediately, so event streams
+// finish and their clients reconnect elsewhere when the server shuts down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subs {
+		for sub := range subs {
+			h.unsubscribe(sub)
+		}
+	}
+}
+
+// Close unregisters the subscription and closes its channel. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.unsubscribe(s)
+}
+
+// Must be called with h.mu held
+func (h *Hub) unsubscribe(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	for _, topic := range sub.topics {
+		delete(h.subs[topic], sub)
+		if len(h.subs[topic]) == 0 {
+			delete(h.subs, topic)
+		}
+	}
+	close(sub.ch)
+}
@@ -7,17 +7,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type AdminRepository struct {
+// Interface for looking up which users are admins. Admins are granted by inserting
+// into the admins collection directly, so there are no write methods.
+type AdminRepository interface {
+    IsAdmin(ctx context.Context, userID string) (bool, error)
+}
+
+type adminRepository struct {
     db *mongo.Collection
 }
 
-func NewAdminRepository(db *mongo.Database) *AdminRepository {
-    return &AdminRepository{
+func NewAdminRepository(db *mongo.Database) AdminRepository {
+    return &adminRepository{
         db: db.Collection("admins"), // Assuming your admin data is in the 'admins' collection
     }
 }
 
-func (repo *AdminRepository) IsAdmin(ctx context.Context, userID string) (bool, error) {
+func (repo *adminRepository) IsAdmin(ctx context.Context, userID string) (bool, error) {
     count, err := repo.db.CountDocuments(ctx, bson.M{"userId": userID})
     if err != nil {
         return false, err
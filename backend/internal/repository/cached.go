@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/cache"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+)
+
+// The cached repositories keep the results of hot reads in an LRU and purge it on every
+// write made through them. Other instances and CLI commands write around the cache, so
+// the LRU's TTL bounds how stale a read can be. Methods that aren't overridden go
+// straight to the wrapped repository; a new write method must purge the cache.
+//
+// Controllers fill in per-user fields such as MyReactions on what they get back, so
+// cached values are copied on the way out and never shared between callers.
+
+type cachedPostRepository struct {
+	PostRepository
+	cache *cache.LRU
+}
+
+// NewCachedPostRepository caches GetPosts and GetPostByID from next in c
+func NewCachedPostRepository(next PostRepository, c *cache.LRU) PostRepository {
+	return &cachedPostRepository{PostRepository: next, cache: c}
+}
+
+func (r *cachedPostRepository) GetPosts(ctx context.Context, filter bson.M, limit int64, skip int64) ([]model.Post, error) {
+	// %#v sorts map keys and includes value types, so equal filters give equal keys
+	key := fmt.Sprintf("posts:%#v:%d:%d", filter, limit, skip)
+	value, err := r.cache.Load(key, func() (interface{}, error) {
+		return r.PostRepository.GetPosts(ctx, filter, limit, skip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]model.Post(nil), value.([]model.Post)...), nil
+}
+
+func (r *cachedPostRepository) GetPostByID(ctx context.Context, id string) (*model.Post, error) {
+	value, err := r.cache.Load("post:"+id, func() (interface{}, error) {
+		return r.PostRepository.GetPostByID(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	post := *value.(*model.Post)
+	return &post, nil
+}
+
+func (r *cachedPostRepository) CreatePost(ctx context.Context, post *model.Post) error {
+	defer r.cache.Purge()
+	return r.PostRepository.CreatePost(ctx, post)
+}
+
+func (r *cachedPostRepository) UpdatePost(ctx context.Context, post model.Post) error {
+	defer r.cache.Purge()
+	return r.PostRepository.UpdatePost(ctx, post)
+}
+
+func (r *cachedPostRepository) DeletePost(ctx context.Context, id string, userID *string) error {
+	defer r.cache.Purge()
+	return r.PostRepository.DeletePost(ctx, id, userID)
+}
+
+func (r *cachedPostRepository) UpsertPostBySlug(ctx context.Context, post *model.Post) (bool, error) {
+	defer r.cache.Purge()
+	return r.PostRepository.UpsertPostBySlug(ctx, post)
+}
+
+type cachedUserRepository struct {
+	UserRepository
+	cache *cache.LRU
+}
+
+// NewCachedUserRepository caches GetUsers from next in c
+func NewCachedUserRepository(next UserRepository, c *cache.LRU) UserRepository {
+	return &cachedUserRepository{UserRepository: next, cache: c}
+}
+
+func (r *cachedUserRepository) GetUsers(ctx context.Context) ([]UserProjection, error) {
+	value, err := r.cache.Load("users", func() (interface{}, error) {
+		return r.UserRepository.GetUsers(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]UserProjection(nil), value.([]UserProjection)...), nil
+}
+
+// Only writes that change the username or createdAt projected by GetUsers purge the cache
+
+func (r *cachedUserRepository) CreateUser(ctx context.Context, user model.User) error {
+	defer r.cache.Purge()
+	return r.UserRepository.CreateUser(ctx, user)
+}
+
+func (r *cachedUserRepository) UpdateUser(ctx context.Context, user model.User) error {
+	defer r.cache.Purge()
+	return r.UserRepository.UpdateUser(ctx, user)
+}
+
+func (r *cachedUserRepository) DeleteUser(ctx context.Context, userID primitive.ObjectID) error {
+	defer r.cache.Purge()
+	return r.UserRepository.DeleteUser(ctx, userID)
+}
+
+type cachedReactionRepository struct {
+	ReactionRepository
+	posts *cache.LRU
+}
+
+// NewCachedReactionRepository purges posts, the cache of a cached post repository, when
+// a reaction changes a post's denormalized reaction counts
+func NewCachedReactionRepository(next ReactionRepository, posts *cache.LRU) ReactionRepository {
+	return &cachedReactionRepository{ReactionRepository: next, posts: posts}
+}
+
+func (r *cachedReactionRepository) AddReaction(ctx context.Context, reaction model.Reaction) (bool, error) {
+	added, err := r.ReactionRepository.AddReaction(ctx, reaction)
+	if added && reaction.TargetType == model.ReactionTargetPost {
+		r.posts.Purge()
+	}
+	return added, err
+}
+
+func (r *cachedReactionRepository) RemoveReaction(ctx context.Context, targetType string, targetID, userID primitive.ObjectID, reactionType string) (bool, error) {
+	removed, err := r.ReactionRepository.RemoveReaction(ctx, targetType, targetID, userID, reactionType)
+	if removed && targetType == model.ReactionTargetPost {
+		r.posts.Purge()
+	}
+	return removed, err
+}
+
+type cachedAdminRepository struct {
+	next  AdminRepository
+	cache *cache.LRU
+}
+
+// NewCachedAdminRepository caches IsAdmin from next in c. Admins are changed directly in
+// the database, so a grant or revocation takes effect once the cached answer expires.
+func NewCachedAdminRepository(next AdminRepository, c *cache.LRU) AdminRepository {
+	return &cachedAdminRepository{next: next, cache: c}
+}
+
+func (r *cachedAdminRepository) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	value, err := r.cache.Load(userID, func() (interface{}, error) {
+		return r.next.IsAdmin(ctx, userID)
+	})
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
@@ -9,13 +9,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type CommentRepository interface {
 	CreateComment(ctx context.Context, comment model.Comment) error
 	GetCommentsByPost(ctx context.Context, postID primitive.ObjectID) ([]model.Comment, error)
+	GetCommentByID(ctx context.Context, id primitive.ObjectID) (*model.Comment, error)
 	UpdateComment(ctx context.Context, id string, userID string, comment model.Comment) error
 	DeleteComment(ctx context.Context, id string, userID *primitive.ObjectID) error
+	GetCommentsByAuthor(ctx context.Context, authorID primitive.ObjectID) ([]model.Comment, error)
+	AnonymizeComments(ctx context.Context, authorID primitive.ObjectID) (int64, error)
+	DeleteCommentsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (int64, error)
+	GetCommentIDsByPosts(ctx context.Context, postIDs []primitive.ObjectID) ([]primitive.ObjectID, error)
 }
 
 type commentRepository struct {
@@ -28,9 +34,22 @@ func NewCommentRepository(db *mongo.Database) CommentRepository {
 	}
 }
 
+// Indexes on the comments collection
+var commentIndexes = []Index{
+	{Name: "postId_createdAt", Keys: bson.D{{Key: "postId", Value: 1}, {Key: "createdAt", Value: 1}}},
+	{Name: "authorId_createdAt", Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "createdAt", Value: 1}}},
+	{Name: "importSource", Keys: bson.D{{Key: "importSource", Value: 1}}, Unique: true, Sparse: true},
+	{Name: "text", Keys: bson.D{{Key: "content", Value: "text"}}},
+}
+
 func (r *commentRepository) CreateComment(ctx context.Context, comment model.Comment) error {
-    comment.ID = primitive.NewObjectID()
-    comment.CreatedAt = time.Now()
+    if comment.ID.IsZero() {
+        comment.ID = primitive.NewObjectID()
+    }
+    if comment.CreatedAt.IsZero() {
+        comment.CreatedAt = time.Now()
+    }
+    comment.UpdatedAt = comment.CreatedAt
     _, err := r.db.InsertOne(ctx, comment)
     return err
 }
@@ -57,6 +76,14 @@ func (r *commentRepository) GetCommentsByPost(ctx context.Context, postID primit
 	return comments, nil
 }
 
+func (r *commentRepository) GetCommentByID(ctx context.Context, id primitive.ObjectID) (*model.Comment, error) {
+    var comment model.Comment
+    if err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&comment); err != nil {
+        return nil, err
+    }
+    return &comment, nil
+}
+
 func (r *commentRepository) UpdateComment(ctx context.Context, id string, userID string, comment model.Comment) error {
     objID, err := primitive.ObjectIDFromHex(id)
     if err != nil {
@@ -65,7 +92,6 @@ func (r *commentRepository) UpdateComment(ctx context.Context, id string, userID
 
     update := bson.M{"$set": bson.M{
         "content": comment.Content,
-        "email": comment.Email, // Updating email for now, but might want to change this
         "updatedAt": time.Now(),
     }}
     filter := bson.M{"_id": objID, "author": userID} // Ensure that the author matches the userID
@@ -104,4 +130,66 @@ func (r *commentRepository) DeleteComment(ctx context.Context, id string, userID
     return nil
 }
 
+// Returns every comment left by a user, oldest first
+func (r *commentRepository) GetCommentsByAuthor(ctx context.Context, authorID primitive.ObjectID) ([]model.Comment, error) {
+    opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+    cur, err := r.db.Find(ctx, bson.M{"authorId": authorID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    comments := []model.Comment{}
+    if err := cur.All(ctx, &comments); err != nil {
+        return nil, err
+    }
+    return comments, nil
+}
 
+// Re-attributes a user's comments to the deleted user placeholder so threads stay readable.
+// Returns the number of comments updated.
+func (r *commentRepository) AnonymizeComments(ctx context.Context, authorID primitive.ObjectID) (int64, error) {
+    update := bson.M{
+        "$set": bson.M{
+            "author":   model.DeletedUsername,
+            "authorId": primitive.NilObjectID,
+        },
+        "$unset": bson.M{"email": ""},
+    }
+    result, err := r.db.UpdateMany(ctx, bson.M{"authorId": authorID}, update)
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+// Returns the IDs of every comment on the given posts
+func (r *commentRepository) GetCommentIDsByPosts(ctx context.Context, postIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+    ids := []primitive.ObjectID{}
+    if len(postIDs) == 0 {
+        return ids, nil
+    }
+    opts := options.Find().SetProjection(bson.M{"_id": 1})
+    cur, err := r.db.Find(ctx, bson.M{"postId": bson.M{"$in": postIDs}}, opts)
+    if err != nil {
+        return nil, err
+    }
+    var comments []model.Comment
+    if err := cur.All(ctx, &comments); err != nil {
+        return nil, err
+    }
+    for _, comment := range comments {
+        ids = append(ids, comment.ID)
+    }
+    return ids, nil
+}
+
+// Deletes every comment on the given posts. Returns the number deleted.
+func (r *commentRepository) DeleteCommentsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (int64, error) {
+    if len(postIDs) == 0 {
+        return 0, nil
+    }
+    result, err := r.db.DeleteMany(ctx, bson.M{"postId": bson.M{"$in": postIDs}})
+    if err != nil {
+        return 0, err
+    }
+    return result.DeletedCount, nil
+}
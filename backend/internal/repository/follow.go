@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Interface for querying who follows whom
+type FollowRepository interface {
+	Follow(ctx context.Context, follow model.Follow) (bool, error)
+	Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) (bool, error)
+	GetFollowers(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) ([]model.Follow, error)
+	GetFollowing(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) ([]model.Follow, error)
+	GetFollowingIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error)
+	DeleteFollowsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+type followRepository struct {
+	db *mongo.Collection
+}
+
+// Create a new follow repository
+func NewFollowRepository(db *mongo.Database) FollowRepository {
+	return &followRepository{
+		db: db.Collection("follows"),
+	}
+}
+
+// Indexes on the follows collection. The unique key makes Follow's upsert idempotent.
+var followIndexes = []Index{
+	{Name: "followerId_followeeId", Keys: bson.D{{Key: "followerId", Value: 1}, {Key: "followeeId", Value: 1}}, Unique: true},
+	{Name: "followerId_createdAt", Keys: bson.D{{Key: "followerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{Name: "followeeId_createdAt", Keys: bson.D{{Key: "followeeId", Value: 1}, {Key: "createdAt", Value: -1}}},
+}
+
+// Records that the follower follows the followee. Returns false if they already did.
+func (r *followRepository) Follow(ctx context.Context, follow model.Follow) (bool, error) {
+	filter := bson.M{"followerId": follow.FollowerID, "followeeId": follow.FolloweeID}
+	update := bson.M{"$setOnInsert": bson.M{
+		"followerUsername": follow.FollowerUsername,
+		"followeeUsername": follow.FolloweeUsername,
+		"createdAt":        time.Now(),
+	}}
+	result, err := r.db.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return result.UpsertedCount > 0, nil
+}
+
+// Removes a follow. Returns false if the follower was not following the followee.
+func (r *followRepository) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) (bool, error) {
+	result, err := r.db.DeleteOne(ctx, bson.M{"followerId": followerID, "followeeId": followeeID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// Returns the users following userID, newest first
+func (r *followRepository) GetFollowers(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) ([]model.Follow, error) {
+	return r.find(ctx, bson.M{"followeeId": userID}, limit, skip)
+}
+
+// Returns the users userID follows, newest first
+func (r *followRepository) GetFollowing(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) ([]model.Follow, error) {
+	return r.find(ctx, bson.M{"followerId": userID}, limit, skip)
+}
+
+// Returns the IDs of every user userID follows
+func (r *followRepository) GetFollowingIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"followeeId": 1})
+	cur, err := r.db.Find(ctx, bson.M{"followerId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cur.Next(ctx) {
+		var follow model.Follow
+		if err := cur.Decode(&follow); err != nil {
+			return nil, err
+		}
+		ids = append(ids, follow.FolloweeID)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *followRepository) find(ctx context.Context, filter bson.M, limit int64, skip int64) ([]model.Follow, error) {
+	opts := options.Find().
+		SetLimit(limit).
+		SetSkip(skip).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := r.db.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	follows := []model.Follow{}
+	if err := cur.All(ctx, &follows); err != nil {
+		return nil, err
+	}
+	return follows, nil
+}
+
+// Removes every follow the user is part of, in either direction. Returns the number deleted.
+func (r *followRepository) DeleteFollowsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.db.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"followerId": userID},
+		bson.M{"followeeId": userID},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+)
+
+// Index is an index a repository's queries rely on. Each repository declares its
+// collection's indexes next to the queries that use them.
+type Index struct {
+	Name    string
+	Keys    bson.D // Field to 1, -1 or "text"
+	Unique  bool
+	Sparse  bool
+	Partial bson.D           // Partial filter expression
+	Weights map[string]int32 // Text index field weights; unlisted text fields weigh 1
+}
+
+// DeclaredIndexes returns every collection's declared indexes, keyed by collection name
+func DeclaredIndexes() map[string][]Index {
+	return map[string][]Index{
+		"users":         userIndexes,
+		"posts":         postIndexes,
+		"comments":      commentIndexes,
+		"reactions":     reactionIndexes,
+		"follows":       followIndexes,
+		"notifications": notificationIndexes,
+		"media":         mediaIndexes,
+	}
+}
+
+// Kinds of difference between declared and actual indexes
+const (
+	IndexMissing = "missing" // Declared but not in the database
+	IndexChanged = "changed" // In the database with a different definition or name
+	IndexExtra   = "extra"   // In the database but not declared
+)
+
+// IndexDiff is one difference between a collection's declared and actual indexes
+type IndexDiff struct {
+	Collection string
+	Kind       string
+	Name       string // Name of the declared index, or of the extra one
+	Actual     string // Name of the existing index for IndexChanged
+	Detail     string
+}
+
+func (d IndexDiff) String() string {
+	s := fmt.Sprintf("%s.%s: %s", d.Collection, d.Name, d.Kind)
+	if d.Detail != "" {
+		s += " (" + d.Detail + ")"
+	}
+	return s
+}
+
+// The parts of a listIndexes result that declarations are compared against
+type indexSpec struct {
+	Name    string           `bson:"name"`
+	Key     bson.D           `bson:"key"`
+	Unique  bool             `bson:"unique"`
+	Sparse  bool             `bson:"sparse"`
+	Partial bson.D           `bson:"partialFilterExpression"`
+	Weights map[string]int32 `bson:"weights"`
+}
+
+// DiffIndexes compares the declared indexes with those in db. Extra indexes are
+// reported but never dropped, since an operator may have added them on purpose.
+func DiffIndexes(ctx context.Context, db *mongo.Database) ([]IndexDiff, error) {
+	var diffs []IndexDiff
+	declared := DeclaredIndexes()
+	collections := make([]string, 0, len(declared))
+	for name := range declared {
+		collections = append(collections, name)
+	}
+	sort.Strings(collections)
+
+	for _, collection := range collections {
+		actual, err := listIndexes(ctx, db.Collection(collection))
+		if err != nil {
+			return nil, fmt.Errorf("listing indexes of %s: %w", collection, err)
+		}
+		diffs = append(diffs, diffCollection(collection, declared[collection], actual)...)
+	}
+	return diffs, nil
+}
+
+func diffCollection(collection string, declared []Index, actual []indexSpec) []IndexDiff {
+	var diffs []IndexDiff
+	matched := map[string]bool{"_id_": true}
+	byName := map[string]indexSpec{}
+	for _, spec := range actual {
+		byName[spec.Name] = spec
+	}
+
+	for _, index := range declared {
+		want := index.spec()
+		if got, ok := byName[index.Name]; ok {
+			matched[got.Name] = true
+			if detail := compareSpecs(want, got); detail != "" {
+				diffs = append(diffs, IndexDiff{Collection: collection, Kind: IndexChanged, Name: index.Name, Actual: got.Name, Detail: detail})
+			}
+			continue
+		}
+		// An index on the same keys under another name blocks creating ours
+		found := false
+		for _, got := range actual {
+			if !matched[got.Name] && keysEqual(want.Key, got.Key) {
+				matched[got.Name] = true
+				found = true
+				diffs = append(diffs, IndexDiff{Collection: collection, Kind: IndexChanged, Name: index.Name, Actual: got.Name, Detail: "exists as " + got.Name})
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, IndexDiff{Collection: collection, Kind: IndexMissing, Name: index.Name, Detail: formatKeys(index.Keys)})
+		}
+	}
+
+	for _, got := range actual {
+		if !matched[got.Name] {
+			diffs = append(diffs, IndexDiff{Collection: collection, Kind: IndexExtra, Name: got.Name, Detail: formatKeys(got.Key)})
+		}
+	}
+	return diffs
+}
+
+// EnsureIndexes creates missing indexes and rebuilds changed ones. A unique index isn't
+// built while the collection holds duplicate keys (see Duplicates), and a changed index
+// is only dropped once a stand-in covers its queries. Errors are collected so the other
+// indexes are still built.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	diffs, err := DiffIndexes(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, diff := range diffs {
+		if diff.Kind == IndexExtra {
+			continue
+		}
+		coll := db.Collection(diff.Collection)
+		index, _ := declaredIndex(diff.Collection, diff.Name)
+		if index.Unique {
+			duplicates, err := duplicateKeys(ctx, coll, index, 1)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("checking %s.%s for duplicates: %w", diff.Collection, diff.Name, err))
+				continue
+			}
+			if len(duplicates) > 0 {
+				errs = append(errs, fmt.Errorf("not building unique index %s.%s: %w", diff.Collection, diff.Name, ErrDuplicateKeys))
+				continue
+			}
+		}
+
+		if diff.Kind == IndexChanged {
+			err = replaceIndex(ctx, coll, diff.Actual, index)
+		} else {
+			_, err = coll.Indexes().CreateOne(ctx, index.model())
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("building %s.%s: %w", diff.Collection, diff.Name, err))
+			continue
+		}
+		logging.FromContext(ctx).Info("Built index", "collection", diff.Collection, "index", diff.Name, "reason", diff.Kind)
+	}
+	return errors.Join(errs...)
+}
+
+// ErrDuplicateKeys is returned for a unique index whose keys documents already share
+var ErrDuplicateKeys = errors.New("documents share keys")
+
+// Duplicate is a key more than one document has, which stops a unique index being built
+type Duplicate struct {
+	Key   string // The key fields and values, as extended JSON
+	Count int
+}
+
+// Duplicates lists up to limit keys of the declared index collection.name that
+// more than one document shares, most shared first. They have to be resolved by hand
+// before the index can be built. Indexes that aren't declared unique have none.
+func Duplicates(ctx context.Context, db *mongo.Database, collection, name string, limit int64) ([]Duplicate, error) {
+	index, ok := declaredIndex(collection, name)
+	if !ok || !index.Unique {
+		return nil, nil
+	}
+	return duplicateKeys(ctx, db.Collection(collection), index, limit)
+}
+
+func declaredIndex(collection, name string) (Index, bool) {
+	for _, index := range DeclaredIndexes()[collection] {
+		if index.Name == name {
+			return index, true
+		}
+	}
+	return Index{}, false
+}
+
+// Groups the documents the index would cover by its keys and returns the groups with more than one
+func duplicateKeys(ctx context.Context, coll *mongo.Collection, index Index, limit int64) ([]Duplicate, error) {
+	var pipeline mongo.Pipeline
+	if index.Partial != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: index.Partial}})
+	}
+	group := bson.D{}
+	var present bson.A
+	for _, key := range index.Keys {
+		group = append(group, bson.E{Key: key.Key, Value: "$" + key.Key})
+		present = append(present, bson.M{key.Key: bson.M{"$exists": true}})
+	}
+	if index.Sparse {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": present}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: group}, {Key: "count", Value: bson.M{"$sum": 1}}}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		bson.D{{Key: "$sort", Value: bson.M{"count": -1}}},
+		bson.D{{Key: "$limit", Value: limit}},
+	)
+
+	cur, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Key   bson.D `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cur.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	duplicates := make([]Duplicate, len(groups))
+	for i, g := range groups {
+		duplicates[i] = Duplicate{Key: formatDoc(g.Key), Count: g.Count}
+	}
+	return duplicates, nil
+}
+
+// Replaces the existing index named actual with index. MongoDB won't hold two indexes
+// on the same keys, so a stand-in on index's keys followed by _id is built first to
+// keep queries indexed, and the old definition is restored if the new one fails.
+// Only one text index is allowed per collection, so text indexes have no stand-in.
+func replaceIndex(ctx context.Context, coll *mongo.Collection, actual string, index Index) error {
+	specs, err := listIndexes(ctx, coll)
+	if err != nil {
+		return err
+	}
+	var old *indexSpec
+	for i := range specs {
+		if specs[i].Name == actual {
+			old = &specs[i]
+		}
+	}
+	if old == nil {
+		_, err := coll.Indexes().CreateOne(ctx, index.model())
+		return err
+	}
+
+	standIn := ""
+	if old.Weights == nil && index.Weights == nil && !index.isText() {
+		standIn = index.Name + "_replacing"
+		keys := append(append(bson.D{}, index.Keys...), bson.E{Key: "_id", Value: 1})
+		model := mongo.IndexModel{Keys: keys, Options: options.Index().SetName(standIn)}
+		if _, err := coll.Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("building stand-in %s: %w", standIn, err)
+		}
+	}
+
+	if _, err := coll.Indexes().DropOne(ctx, actual); err != nil {
+		err = fmt.Errorf("dropping %s: %w", actual, err)
+	} else if _, err = coll.Indexes().CreateOne(ctx, index.model()); err != nil {
+		if _, restoreErr := coll.Indexes().CreateOne(ctx, old.model()); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("restoring %s: %w", actual, restoreErr))
+		}
+	}
+	if standIn != "" {
+		if _, dropErr := coll.Indexes().DropOne(ctx, standIn); dropErr != nil {
+			err = errors.Join(err, fmt.Errorf("dropping stand-in %s: %w", standIn, dropErr))
+		}
+	}
+	return err
+}
+
+func listIndexes(ctx context.Context, coll *mongo.Collection) ([]indexSpec, error) {
+	cur, err := coll.Indexes().List(ctx)
+	if err != nil {
+		// Collections are created by their first insert, so a fresh database has none yet
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var specs []indexSpec
+	if err := cur.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func (index Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(index.Name)
+	if index.Unique {
+		opts.SetUnique(true)
+	}
+	if index.Sparse {
+		opts.SetSparse(true)
+	}
+	if index.Partial != nil {
+		opts.SetPartialFilterExpression(index.Partial)
+	}
+	if index.Weights != nil {
+		opts.SetWeights(index.Weights)
+	}
+	return mongo.IndexModel{Keys: index.Keys, Options: opts}
+}
+
+func (index Index) isText() bool {
+	for _, key := range index.Keys {
+		if key.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the model that recreates an index as listIndexes reported it. Text indexes
+// are rebuilt from their weights, which name every text field.
+func (spec indexSpec) model() mongo.IndexModel {
+	opts := options.Index().SetName(spec.Name)
+	if spec.Unique {
+		opts.SetUnique(true)
+	}
+	if spec.Sparse {
+		opts.SetSparse(true)
+	}
+	if spec.Partial != nil {
+		opts.SetPartialFilterExpression(spec.Partial)
+	}
+	keys := bson.D{}
+	for _, key := range spec.Key {
+		if key.Key == "_fts" {
+			fields := make([]string, 0, len(spec.Weights))
+			for field := range spec.Weights {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				keys = append(keys, bson.E{Key: field, Value: "text"})
+			}
+			opts.SetWeights(spec.Weights)
+			continue
+		}
+		if key.Key == "_ftsx" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return mongo.IndexModel{Keys: keys, Options: opts}
+}
+
+// Returns the declaration in the form listIndexes reports it. Text indexes are stored
+// as _fts/_ftsx keys with every text field's weight.
+func (index Index) spec() indexSpec {
+	spec := indexSpec{Name: index.Name, Unique: index.Unique, Sparse: index.Sparse, Partial: index.Partial}
+	text := false
+	for _, key := range index.Keys {
+		if key.Value == "text" {
+			if !text {
+				text = true
+				spec.Key = append(spec.Key, bson.E{Key: "_fts", Value: "text"}, bson.E{Key: "_ftsx", Value: 1})
+				spec.Weights = map[string]int32{}
+			}
+			weight := index.Weights[key.Key]
+			if weight == 0 {
+				weight = 1
+			}
+			spec.Weights[key.Key] = weight
+			continue
+		}
+		spec.Key = append(spec.Key, key)
+	}
+	return spec
+}
+
+// Describes how got differs from want, or returns "" if they are the same
+func compareSpecs(want, got indexSpec) string {
+	var diffs []string
+	if !keysEqual(want.Key, got.Key) {
+		diffs = append(diffs, fmt.Sprintf("keys %s, want %s", formatKeys(got.Key), formatKeys(want.Key)))
+	}
+	if want.Unique != got.Unique {
+		diffs = append(diffs, fmt.Sprintf("unique %t, want %t", got.Unique, want.Unique))
+	}
+	if want.Sparse != got.Sparse {
+		diffs = append(diffs, fmt.Sprintf("sparse %t, want %t", got.Sparse, want.Sparse))
+	}
+	if formatDoc(want.Partial) != formatDoc(got.Partial) {
+		diffs = append(diffs, fmt.Sprintf("partial filter %s, want %s", formatDoc(got.Partial), formatDoc(want.Partial)))
+	}
+	if fmt.Sprint(want.Weights) != fmt.Sprint(got.Weights) {
+		diffs = append(diffs, fmt.Sprintf("weights %v, want %v", got.Weights, want.Weights))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// Compares key documents, treating 1, int64(1) and 1.0 as the same direction
+func keysEqual(a, b bson.D) bool {
+	return formatKeys(a) == formatKeys(b)
+}
+
+func formatKeys(keys bson.D) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s: %v", key.Key, key.Value)
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func formatDoc(doc bson.D) string {
+	if doc == nil {
+		return "none"
+	}
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return fmt.Sprint(doc)
+	}
+	return string(data)
+}
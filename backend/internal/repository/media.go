@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Interface for the metadata of uploaded files; the files themselves live in storage
+type MediaRepository interface {
+	CreateMedia(ctx context.Context, media *model.Media) error
+	GetMedia(ctx context.Context, id primitive.ObjectID) (*model.Media, error)
+	GetMediaByOwner(ctx context.Context, ownerID primitive.ObjectID, limit int64, skip int64) ([]model.Media, error)
+	DeleteMedia(ctx context.Context, id primitive.ObjectID, ownerID *primitive.ObjectID) error
+	KeyInUse(ctx context.Context, key string) (bool, error)
+}
+
+type mediaRepository struct {
+	db *mongo.Collection
+}
+
+// Create a new media repository
+func NewMediaRepository(db *mongo.Database) MediaRepository {
+	return &mediaRepository{
+		db: db.Collection("media"),
+	}
+}
+
+// Indexes on the media collection. KeyInUse looks files up by storage key before deleting them.
+var mediaIndexes = []Index{
+	{Name: "ownerId_createdAt", Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{Name: "key", Keys: bson.D{{Key: "key", Value: 1}}},
+	{Name: "variants.key", Keys: bson.D{{Key: "variants.key", Value: 1}}},
+}
+
+// Inserts media metadata. The ID may be set by the caller so it can be used in the storage key.
+func (r *mediaRepository) CreateMedia(ctx context.Context, media *model.Media) error {
+	if media.ID.IsZero() {
+		media.ID = primitive.NewObjectID()
+	}
+	_, err := r.db.InsertOne(ctx, media)
+	return err
+}
+
+// Find media by its ID
+func (r *mediaRepository) GetMedia(ctx context.Context, id primitive.ObjectID) (*model.Media, error) {
+	var media model.Media
+	if err := r.db.FindOne(ctx, bson.M{"_id": id}).Decode(&media); err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// Returns a user's uploads, newest first
+func (r *mediaRepository) GetMediaByOwner(ctx context.Context, ownerID primitive.ObjectID, limit int64, skip int64) ([]model.Media, error) {
+	opts := options.Find().
+		SetLimit(limit).
+		SetSkip(skip).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := r.db.Find(ctx, bson.M{"ownerId": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	media := []model.Media{}
+	if err := cur.All(ctx, &media); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// Deletes media metadata, checking ownership when ownerID is provided
+func (r *mediaRepository) DeleteMedia(ctx context.Context, id primitive.ObjectID, ownerID *primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	if ownerID != nil {
+		filter["ownerId"] = *ownerID // Add owner check only if ownerID is provided
+	}
+
+	result, err := r.db.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		if ownerID != nil {
+			return fmt.Errorf("no media found with given ID or unauthorized")
+		}
+		return fmt.Errorf("no media found with given ID")
+	}
+	return nil
+}
+
+// Reports whether any media still references a storage key. Files are named by
+// content hash, so identical uploads share a key and must not be deleted while in use.
+func (r *mediaRepository) KeyInUse(ctx context.Context, key string) (bool, error) {
+	count, err := r.db.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"key": key},
+		bson.M{"variants.key": key},
+	}})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Interface for storing and reading user notifications
+type NotificationRepository interface {
+	CreateNotification(ctx context.Context, notification *model.Notification) error
+	GetNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int64, skip int64) ([]model.Notification, error)
+	CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	MarkRead(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) (int64, error)
+	DeleteNotificationsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+	DeleteNotificationsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (int64, error)
+}
+
+type notificationRepository struct {
+	db *mongo.Collection
+}
+
+// Create a new notification repository
+func NewNotificationRepository(db *mongo.Database) NotificationRepository {
+	return &notificationRepository{
+		db: db.Collection("notifications"),
+	}
+}
+
+// Indexes on the notifications collection
+var notificationIndexes = []Index{
+	{Name: "userId_createdAt", Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{Name: "actorId", Keys: bson.D{{Key: "actorId", Value: 1}}},
+}
+
+// Inserts a new unread notification
+func (r *notificationRepository) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	notification.ID = primitive.NewObjectID()
+	notification.Read = false
+	notification.CreatedAt = time.Now()
+	_, err := r.db.InsertOne(ctx, notification)
+	return err
+}
+
+// Returns a user's notifications, newest first
+func (r *notificationRepository) GetNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int64, skip int64) ([]model.Notification, error) {
+	filter := bson.M{"userId": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+	opts := options.Find().
+		SetLimit(limit).
+		SetSkip(skip).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := r.db.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	notifications := []model.Notification{}
+	if err := cur.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// Returns how many unread notifications a user has
+func (r *notificationRepository) CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.db.CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+}
+
+// Marks the given notifications as read, or all of them when ids is empty.
+// Only notifications owned by userID are touched. Returns the number updated.
+func (r *notificationRepository) MarkRead(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) (int64, error) {
+	filter := bson.M{"userId": userID, "read": false}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+	result, err := r.db.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// Removes notifications sent to the user and those naming the user as the actor.
+// Returns the number deleted.
+func (r *notificationRepository) DeleteNotificationsByUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	result, err := r.db.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"userId": userID},
+		bson.M{"actorId": userID},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// Removes notifications about the given posts or the comments on them. Returns the
+// number deleted.
+func (r *notificationRepository) DeleteNotificationsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (int64, error) {
+	if len(postIDs) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.DeleteMany(ctx, bson.M{"postId": bson.M{"$in": postIDs}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
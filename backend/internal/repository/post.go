@@ -2,9 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/slug"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,9 +19,11 @@ type PostRepository interface {
 	CreatePost(ctx context.Context, post *model.Post) error
 	GetPosts(ctx context.Context, filter bson.M, limit int64, skip int64) ([]model.Post, error)
 	GetPostByID(ctx context.Context, id string) (*model.Post, error)
+	GetPostBySlug(ctx context.Context, slug string) (*model.Post, error)
 	UpdatePost(ctx context.Context, post model.Post) error
 	DeletePost(ctx context.Context, id string, userID *string) error
     GetPostsByUser(ctx context.Context, userID string) ([]model.Post, error)
+	UpsertPostBySlug(ctx context.Context, post *model.Post) (bool, error)
 }
 
 type postRepository struct {
@@ -32,8 +37,35 @@ func NewPostRepository(db *mongo.Database) PostRepository {
 	}
 }
 
-// Inserts a new post into the database
+// Indexes on the posts collection. Slugs are unique across current and previous slugs
+// by construction in uniqueSlug; the index backs that up against concurrent writes.
+var postIndexes = []Index{
+	{Name: "publishedAt", Keys: bson.D{{Key: "publishedAt", Value: -1}}},
+	{Name: "authorId_publishedAt", Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "publishedAt", Value: -1}}},
+	{Name: "tags_publishedAt", Keys: bson.D{{Key: "tags", Value: 1}, {Key: "publishedAt", Value: -1}}},
+	{Name: "slug", Keys: bson.D{{Key: "slug", Value: 1}}, Unique: true, Partial: bson.D{{Key: "slug", Value: bson.D{{Key: "$type", Value: "string"}}}}},
+	{Name: "previousSlugs", Keys: bson.D{{Key: "previousSlugs", Value: 1}}},
+	{Name: "importSource", Keys: bson.D{{Key: "importSource", Value: 1}}, Unique: true, Sparse: true},
+	{
+		Name:    "text",
+		Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "tags", Value: "text"}, {Key: "content", Value: "text"}},
+		Weights: map[string]int32{"title": 10, "tags": 5},
+	},
+}
+
+// Inserts a new post into the database, giving it a unique slug derived from the
+// requested slug if the caller set one, as UpsertPostBySlug does, or from its title
 func (r *postRepository) CreatePost(ctx context.Context, post *model.Post) error {
+	base := post.Slug
+	if base == "" {
+		base = post.Title
+	}
+	postSlug, err := r.uniqueSlug(ctx, slug.Make(base), primitive.NilObjectID)
+	if err != nil {
+		return err
+	}
+	post.Slug = postSlug
+
 	result, err := r.db.InsertOne(ctx, post)
     if err != nil {
         return err
@@ -82,13 +114,45 @@ func (r *postRepository) GetPostByID(ctx context.Context, id string) (*model.Pos
     return &post, nil
 }
 
-// Updates a post in the database
+// Find a post by its current or a previous slug. Callers can compare the
+// returned post's Slug with the one they asked for to detect an old permalink.
+func (r *postRepository) GetPostBySlug(ctx context.Context, postSlug string) (*model.Post, error) {
+    var post model.Post
+    // uniqueSlug never hands out a slug another post has used, so at most one post matches
+    filter := bson.M{"$or": bson.A{
+        bson.M{"slug": postSlug},
+        bson.M{"previousSlugs": postSlug},
+    }}
+    if err := r.db.FindOne(ctx, filter).Decode(&post); err != nil {
+        return nil, err
+    }
+    return &post, nil
+}
+
+// Updates a post in the database. When the title changes the post gets a new
+// slug and the old one is kept in previousSlugs so existing links keep working.
 func (r *postRepository) UpdatePost(ctx context.Context, post model.Post) error {
-    update := bson.M{
-        "$set": bson.M{
-            "title":   post.Title,
-            "content": post.Content,
-        },
+    var existing model.Post
+    if err := r.db.FindOne(ctx, bson.M{"_id": post.ID}).Decode(&existing); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return fmt.Errorf("no post found with given ID")
+        }
+        return err
+    }
+
+    set := bson.M{
+        "title":        post.Title,
+        "content":      post.Content,
+        "tags":         post.Tags,
+        "coverMediaId": post.CoverMediaID,
+        "coverImage":   post.CoverImage,
+    }
+    update := bson.M{"$set": set}
+
+    if existing.Slug == "" || slug.Make(post.Title) != slug.Make(existing.Title) {
+        if err := r.setSlug(ctx, set, existing, slug.Make(post.Title)); err != nil {
+            return err
+        }
     }
 
     filter := bson.M{"_id": post.ID}
@@ -148,3 +212,91 @@ func (r *postRepository) GetPostsByUser(ctx context.Context, userID string) ([]m
 
     return posts, nil
 }
+
+// ErrSlugTaken is returned by UpsertPostBySlug when the slug belongs to another author's post
+var ErrSlugTaken = errors.New("slug belongs to another author's post")
+
+// Creates the post, or updates the post that has or had the same slug if post's author
+// wrote it. The slug and publish date come from post; the cover image and reactions of
+// an existing post are kept. Returns true when a new post was created.
+func (r *postRepository) UpsertPostBySlug(ctx context.Context, post *model.Post) (bool, error) {
+    base := post.Slug
+    if base == "" {
+        base = post.Title
+    }
+    post.Slug = slug.Make(base)
+    existing, err := r.GetPostBySlug(ctx, post.Slug)
+    if err == mongo.ErrNoDocuments {
+        if post.PublishedAt.IsZero() {
+            post.PublishedAt = time.Now()
+        }
+        return true, r.CreatePost(ctx, post)
+    }
+    if err != nil {
+        return false, err
+    }
+    // Importing one author's files must never take over another author's post
+    if existing.AuthorID != post.AuthorID {
+        return false, fmt.Errorf("%w: %s", ErrSlugTaken, post.Slug)
+    }
+
+    set := bson.M{
+        "title":          post.Title,
+        "content":        post.Content,
+        "tags":           post.Tags,
+        "authorUsername": post.AuthorUsername,
+    }
+    if !post.PublishedAt.IsZero() {
+        set["publishedAt"] = post.PublishedAt
+    }
+    // Matched on a previous slug; the requested one becomes current again
+    if existing.Slug != post.Slug {
+        if err := r.setSlug(ctx, set, *existing, post.Slug); err != nil {
+            return false, err
+        }
+    }
+
+    if _, err := r.db.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": set}); err != nil {
+        return false, err
+    }
+    post.ID = existing.ID
+    return false, nil
+}
+
+// Adds a new unique slug built from base to set, keeping the post's old slugs in previousSlugs
+func (r *postRepository) setSlug(ctx context.Context, set bson.M, existing model.Post, base string) error {
+    newSlug, err := r.uniqueSlug(ctx, base, existing.ID)
+    if err != nil {
+        return err
+    }
+    set["slug"] = newSlug
+    var previous []string
+    for _, s := range append(existing.PreviousSlugs, existing.Slug) {
+        if s != "" && s != newSlug {
+            previous = append(previous, s)
+        }
+    }
+    set["previousSlugs"] = previous
+    return nil
+}
+
+// Returns base, or base with the lowest numeric suffix that no other post uses as a current or previous slug
+func (r *postRepository) uniqueSlug(ctx context.Context, base string, postID primitive.ObjectID) (string, error) {
+    for n := 1; ; n++ {
+        candidate := slug.WithSuffix(base, n)
+        filter := bson.M{
+            "_id": bson.M{"$ne": postID},
+            "$or": bson.A{
+                bson.M{"slug": candidate},
+                bson.M{"previousSlugs": candidate},
+            },
+        }
+        count, err := r.db.CountDocuments(ctx, filter)
+        if err != nil {
+            return "", err
+        }
+        if count == 0 {
+            return candidate, nil
+        }
+    }
+}
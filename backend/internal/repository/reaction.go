@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrReactionTargetNotFound is returned when a reaction's target doesn't exist
+var ErrReactionTargetNotFound = errors.New("reaction target not found")
+
+// Interface for storing reactions and keeping the denormalized counters on posts and comments in sync
+type ReactionRepository interface {
+	AddReaction(ctx context.Context, reaction model.Reaction) (bool, error)
+	RemoveReaction(ctx context.Context, targetType string, targetID, userID primitive.ObjectID, reactionType string) (bool, error)
+	GetReactions(ctx context.Context, targetType string, targetID primitive.ObjectID, reactionType string, limit int64, skip int64) ([]model.Reaction, error)
+	GetUserReactions(ctx context.Context, targetType string, targetIDs []primitive.ObjectID, userID primitive.ObjectID) (map[primitive.ObjectID][]string, error)
+	GetReactionsByUser(ctx context.Context, userID primitive.ObjectID) ([]model.Reaction, error)
+	DeleteReactionsByTargets(ctx context.Context, targetType string, targetIDs []primitive.ObjectID) (int64, error)
+}
+
+type reactionRepository struct {
+	db       *mongo.Collection
+	posts    *mongo.Collection
+	comments *mongo.Collection
+}
+
+// Create a new reaction repository
+func NewReactionRepository(db *mongo.Database) ReactionRepository {
+	return &reactionRepository{
+		db:       db.Collection("reactions"),
+		posts:    db.Collection("posts"),
+		comments: db.Collection("comments"),
+	}
+}
+
+// Indexes on the reactions collection. The unique key makes AddReaction's upsert
+// idempotent when the same reaction is sent twice at once.
+var reactionIndexes = []Index{
+	{
+		Name:   "targetType_targetId_userId_type",
+		Keys:   bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "userId", Value: 1}, {Key: "type", Value: 1}},
+		Unique: true,
+	},
+	{Name: "targetType_targetId_createdAt", Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{Name: "userId_createdAt", Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}},
+}
+
+// Returns the collection holding the documents a reaction target type points at
+func (r *reactionRepository) targetCollection(targetType string) (*mongo.Collection, error) {
+	switch targetType {
+	case model.ReactionTargetPost:
+		return r.posts, nil
+	case model.ReactionTargetComment:
+		return r.comments, nil
+	}
+	return nil, fmt.Errorf("unknown reaction target: %s", targetType)
+}
+
+// Adds a reaction if the user has not already left one of the same type on the target.
+// Returns true when a new reaction was stored.
+func (r *reactionRepository) AddReaction(ctx context.Context, reaction model.Reaction) (bool, error) {
+	target, err := r.targetCollection(reaction.TargetType)
+	if err != nil {
+		return false, err
+	}
+	if !model.IsValidReactionType(reaction.Type) {
+		return false, fmt.Errorf("invalid reaction type: %s", reaction.Type)
+	}
+
+	count, err := target.CountDocuments(ctx, bson.M{"_id": reaction.TargetID})
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return false, fmt.Errorf("no %s found with given ID: %w", reaction.TargetType, ErrReactionTargetNotFound)
+	}
+
+	// Upsert on the (user, target, type) key so a repeated reaction is a no-op
+	filter := bson.M{
+		"targetType": reaction.TargetType,
+		"targetId":   reaction.TargetID,
+		"userId":     reaction.UserID,
+		"type":       reaction.Type,
+	}
+	update := bson.M{"$setOnInsert": bson.M{
+		"username":  reaction.Username,
+		"createdAt": time.Now(),
+	}}
+	result, err := r.db.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	if result.UpsertedCount == 0 {
+		return false, nil
+	}
+
+	_, err = target.UpdateOne(ctx, bson.M{"_id": reaction.TargetID}, bson.M{
+		"$inc": bson.M{"reactionCounts." + reaction.Type: 1},
+	})
+	if err != nil {
+		// Take the reaction back out so the counter doesn't fall behind the reactions
+		// stored; the client can simply try again
+		if _, delErr := r.db.DeleteOne(context.WithoutCancel(ctx), filter); delErr != nil {
+			return false, fmt.Errorf("%w (rolling back reaction: %v)", err, delErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Removes a user's reaction of the given type. Returns true when a reaction was deleted.
+func (r *reactionRepository) RemoveReaction(ctx context.Context, targetType string, targetID, userID primitive.ObjectID, reactionType string) (bool, error) {
+	target, err := r.targetCollection(targetType)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.DeleteOne(ctx, bson.M{
+		"targetType": targetType,
+		"targetId":   targetID,
+		"userId":     userID,
+		"type":       reactionType,
+	})
+	if err != nil {
+		return false, err
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	_, err = target.UpdateOne(ctx, bson.M{"_id": targetID}, bson.M{
+		"$inc": bson.M{"reactionCounts." + reactionType: -1},
+	})
+	return true, err
+}
+
+// Returns who reacted to a target, newest first, optionally filtered by reaction type
+func (r *reactionRepository) GetReactions(ctx context.Context, targetType string, targetID primitive.ObjectID, reactionType string, limit int64, skip int64) ([]model.Reaction, error) {
+	filter := bson.M{"targetType": targetType, "targetId": targetID}
+	if reactionType != "" {
+		filter["type"] = reactionType
+	}
+	opts := options.Find().
+		SetLimit(limit).
+		SetSkip(skip).
+		SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cur, err := r.db.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	reactions := []model.Reaction{}
+	if err := cur.All(ctx, &reactions); err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// Returns the reaction types a user has left on each of the given targets
+func (r *reactionRepository) GetUserReactions(ctx context.Context, targetType string, targetIDs []primitive.ObjectID, userID primitive.ObjectID) (map[primitive.ObjectID][]string, error) {
+	mine := make(map[primitive.ObjectID][]string)
+	if len(targetIDs) == 0 {
+		return mine, nil
+	}
+
+	filter := bson.M{
+		"targetType": targetType,
+		"targetId":   bson.M{"$in": targetIDs},
+		"userId":     userID,
+	}
+	cur, err := r.db.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var reaction model.Reaction
+		if err := cur.Decode(&reaction); err != nil {
+			return nil, err
+		}
+		mine[reaction.TargetID] = append(mine[reaction.TargetID], reaction.Type)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return mine, nil
+}
+
+// Returns every reaction a user has left, oldest first
+func (r *reactionRepository) GetReactionsByUser(ctx context.Context, userID primitive.ObjectID) ([]model.Reaction, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cur, err := r.db.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	reactions := []model.Reaction{}
+	if err := cur.All(ctx, &reactions); err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+// Deletes every reaction on the given targets, for when the targets themselves are
+// deleted. Counters aren't touched. Returns the number deleted.
+func (r *reactionRepository) DeleteReactionsByTargets(ctx context.Context, targetType string, targetIDs []primitive.ObjectID) (int64, error) {
+	if len(targetIDs) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.DeleteMany(ctx, bson.M{"targetType": targetType, "targetId": bson.M{"$in": targetIDs}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/tracing"
+)
+
+// The traced repositories add a span around every repository call, so a trace shows
+// which method a request spent its time in; the driver's own spans for the commands
+// each method sends are nested underneath.
+
+type tracedUserRepository struct {
+	next UserRepository
+}
+
+// NewTracedUserRepository wraps next so each method call is recorded as a span
+func NewTracedUserRepository(next UserRepository) UserRepository {
+	return &tracedUserRepository{next: next}
+}
+
+func (r *tracedUserRepository) CreateUser(ctx context.Context, user model.User) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.CreateUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreateUser(ctx, user)
+}
+
+func (r *tracedUserRepository) GetUser(ctx context.Context, id string) (result *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUser(ctx, id)
+}
+
+func (r *tracedUserRepository) GetUsers(ctx context.Context) (result []UserProjection, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetUsers")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUsers(ctx)
+}
+
+func (r *tracedUserRepository) ValidateCredentials(ctx context.Context, username, password string) (result *model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.ValidateCredentials")
+	defer func() { tracing.End(span, err) }()
+	return r.next.ValidateCredentials(ctx, username, password)
+}
+
+func (r *tracedUserRepository) GetUserByUsername(ctx context.Context, username string) (result model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetUserByUsername")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUserByUsername(ctx, username)
+}
+
+func (r *tracedUserRepository) GetUserByImportSource(ctx context.Context, source string) (result model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetUserByImportSource")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUserByImportSource(ctx, source)
+}
+
+func (r *tracedUserRepository) UpdateUser(ctx context.Context, user model.User) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.UpdateUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdateUser(ctx, user)
+}
+
+func (r *tracedUserRepository) UpdateNotificationPreferences(ctx context.Context, userID primitive.ObjectID, prefs model.NotificationPreferences) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.UpdateNotificationPreferences")
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdateNotificationPreferences(ctx, userID, prefs)
+}
+
+func (r *tracedUserRepository) SetDeletionRequested(ctx context.Context, userID primitive.ObjectID, at *time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.SetDeletionRequested")
+	defer func() { tracing.End(span, err) }()
+	return r.next.SetDeletionRequested(ctx, userID, at)
+}
+
+func (r *tracedUserRepository) GetUsersPendingDeletion(ctx context.Context, requestedBefore time.Time) (result []model.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetUsersPendingDeletion")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUsersPendingDeletion(ctx, requestedBefore)
+}
+
+func (r *tracedUserRepository) DeleteUser(ctx context.Context, userID primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteUser(ctx, userID)
+}
+
+type tracedPostRepository struct {
+	next PostRepository
+}
+
+// NewTracedPostRepository wraps next so each method call is recorded as a span
+func NewTracedPostRepository(next PostRepository) PostRepository {
+	return &tracedPostRepository{next: next}
+}
+
+func (r *tracedPostRepository) CreatePost(ctx context.Context, post *model.Post) (err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.CreatePost")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreatePost(ctx, post)
+}
+
+func (r *tracedPostRepository) GetPosts(ctx context.Context, filter bson.M, limit int64, skip int64) (result []model.Post, err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.GetPosts")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetPosts(ctx, filter, limit, skip)
+}
+
+func (r *tracedPostRepository) GetPostByID(ctx context.Context, id string) (result *model.Post, err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.GetPostByID")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetPostByID(ctx, id)
+}
+
+func (r *tracedPostRepository) GetPostBySlug(ctx context.Context, slug string) (result *model.Post, err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.GetPostBySlug")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetPostBySlug(ctx, slug)
+}
+
+func (r *tracedPostRepository) UpdatePost(ctx context.Context, post model.Post) (err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.UpdatePost")
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdatePost(ctx, post)
+}
+
+func (r *tracedPostRepository) DeletePost(ctx context.Context, id string, userID *string) (err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.DeletePost")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeletePost(ctx, id, userID)
+}
+
+func (r *tracedPostRepository) GetPostsByUser(ctx context.Context, userID string) (result []model.Post, err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.GetPostsByUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetPostsByUser(ctx, userID)
+}
+
+func (r *tracedPostRepository) UpsertPostBySlug(ctx context.Context, post *model.Post) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "PostRepository.UpsertPostBySlug")
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpsertPostBySlug(ctx, post)
+}
+
+type tracedCommentRepository struct {
+	next CommentRepository
+}
+
+// NewTracedCommentRepository wraps next so each method call is recorded as a span
+func NewTracedCommentRepository(next CommentRepository) CommentRepository {
+	return &tracedCommentRepository{next: next}
+}
+
+func (r *tracedCommentRepository) CreateComment(ctx context.Context, comment model.Comment) (err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.CreateComment")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreateComment(ctx, comment)
+}
+
+func (r *tracedCommentRepository) GetCommentsByPost(ctx context.Context, postID primitive.ObjectID) (result []model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.GetCommentsByPost")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetCommentsByPost(ctx, postID)
+}
+
+func (r *tracedCommentRepository) GetCommentByID(ctx context.Context, id primitive.ObjectID) (result *model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.GetCommentByID")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetCommentByID(ctx, id)
+}
+
+func (r *tracedCommentRepository) UpdateComment(ctx context.Context, id string, userID string, comment model.Comment) (err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.UpdateComment")
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdateComment(ctx, id, userID, comment)
+}
+
+func (r *tracedCommentRepository) DeleteComment(ctx context.Context, id string, userID *primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.DeleteComment")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteComment(ctx, id, userID)
+}
+
+func (r *tracedCommentRepository) GetCommentsByAuthor(ctx context.Context, authorID primitive.ObjectID) (result []model.Comment, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.GetCommentsByAuthor")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetCommentsByAuthor(ctx, authorID)
+}
+
+func (r *tracedCommentRepository) AnonymizeComments(ctx context.Context, authorID primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.AnonymizeComments")
+	defer func() { tracing.End(span, err) }()
+	return r.next.AnonymizeComments(ctx, authorID)
+}
+
+func (r *tracedCommentRepository) DeleteCommentsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.DeleteCommentsByPosts")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteCommentsByPosts(ctx, postIDs)
+}
+
+func (r *tracedCommentRepository) GetCommentIDsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (result []primitive.ObjectID, err error) {
+	ctx, span := tracing.Start(ctx, "CommentRepository.GetCommentIDsByPosts")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetCommentIDsByPosts(ctx, postIDs)
+}
+
+type tracedReactionRepository struct {
+	next ReactionRepository
+}
+
+// NewTracedReactionRepository wraps next so each method call is recorded as a span
+func NewTracedReactionRepository(next ReactionRepository) ReactionRepository {
+	return &tracedReactionRepository{next: next}
+}
+
+func (r *tracedReactionRepository) AddReaction(ctx context.Context, reaction model.Reaction) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.AddReaction")
+	defer func() { tracing.End(span, err) }()
+	return r.next.AddReaction(ctx, reaction)
+}
+
+func (r *tracedReactionRepository) RemoveReaction(ctx context.Context, targetType string, targetID, userID primitive.ObjectID, reactionType string) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.RemoveReaction")
+	defer func() { tracing.End(span, err) }()
+	return r.next.RemoveReaction(ctx, targetType, targetID, userID, reactionType)
+}
+
+func (r *tracedReactionRepository) GetReactions(ctx context.Context, targetType string, targetID primitive.ObjectID, reactionType string, limit int64, skip int64) (result []model.Reaction, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.GetReactions")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetReactions(ctx, targetType, targetID, reactionType, limit, skip)
+}
+
+func (r *tracedReactionRepository) GetUserReactions(ctx context.Context, targetType string, targetIDs []primitive.ObjectID, userID primitive.ObjectID) (result map[primitive.ObjectID][]string, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.GetUserReactions")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUserReactions(ctx, targetType, targetIDs, userID)
+}
+
+func (r *tracedReactionRepository) GetReactionsByUser(ctx context.Context, userID primitive.ObjectID) (result []model.Reaction, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.GetReactionsByUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetReactionsByUser(ctx, userID)
+}
+
+func (r *tracedReactionRepository) DeleteReactionsByTargets(ctx context.Context, targetType string, targetIDs []primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "ReactionRepository.DeleteReactionsByTargets")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteReactionsByTargets(ctx, targetType, targetIDs)
+}
+
+type tracedFollowRepository struct {
+	next FollowRepository
+}
+
+// NewTracedFollowRepository wraps next so each method call is recorded as a span
+func NewTracedFollowRepository(next FollowRepository) FollowRepository {
+	return &tracedFollowRepository{next: next}
+}
+
+func (r *tracedFollowRepository) Follow(ctx context.Context, follow model.Follow) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.Follow")
+	defer func() { tracing.End(span, err) }()
+	return r.next.Follow(ctx, follow)
+}
+
+func (r *tracedFollowRepository) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.Unfollow")
+	defer func() { tracing.End(span, err) }()
+	return r.next.Unfollow(ctx, followerID, followeeID)
+}
+
+func (r *tracedFollowRepository) GetFollowers(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) (result []model.Follow, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.GetFollowers")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetFollowers(ctx, userID, limit, skip)
+}
+
+func (r *tracedFollowRepository) GetFollowing(ctx context.Context, userID primitive.ObjectID, limit int64, skip int64) (result []model.Follow, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.GetFollowing")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetFollowing(ctx, userID, limit, skip)
+}
+
+func (r *tracedFollowRepository) GetFollowingIDs(ctx context.Context, userID primitive.ObjectID) (result []primitive.ObjectID, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.GetFollowingIDs")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetFollowingIDs(ctx, userID)
+}
+
+func (r *tracedFollowRepository) DeleteFollowsByUser(ctx context.Context, userID primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "FollowRepository.DeleteFollowsByUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteFollowsByUser(ctx, userID)
+}
+
+type tracedNotificationRepository struct {
+	next NotificationRepository
+}
+
+// NewTracedNotificationRepository wraps next so each method call is recorded as a span
+func NewTracedNotificationRepository(next NotificationRepository) NotificationRepository {
+	return &tracedNotificationRepository{next: next}
+}
+
+func (r *tracedNotificationRepository) CreateNotification(ctx context.Context, notification *model.Notification) (err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.CreateNotification")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreateNotification(ctx, notification)
+}
+
+func (r *tracedNotificationRepository) GetNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int64, skip int64) (result []model.Notification, err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.GetNotifications")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetNotifications(ctx, userID, unreadOnly, limit, skip)
+}
+
+func (r *tracedNotificationRepository) CountUnread(ctx context.Context, userID primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.CountUnread")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CountUnread(ctx, userID)
+}
+
+func (r *tracedNotificationRepository) MarkRead(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.MarkRead")
+	defer func() { tracing.End(span, err) }()
+	return r.next.MarkRead(ctx, userID, ids)
+}
+
+func (r *tracedNotificationRepository) DeleteNotificationsByUser(ctx context.Context, userID primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.DeleteNotificationsByUser")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteNotificationsByUser(ctx, userID)
+}
+
+func (r *tracedNotificationRepository) DeleteNotificationsByPosts(ctx context.Context, postIDs []primitive.ObjectID) (result int64, err error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.DeleteNotificationsByPosts")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteNotificationsByPosts(ctx, postIDs)
+}
+
+type tracedMediaRepository struct {
+	next MediaRepository
+}
+
+// NewTracedMediaRepository wraps next so each method call is recorded as a span
+func NewTracedMediaRepository(next MediaRepository) MediaRepository {
+	return &tracedMediaRepository{next: next}
+}
+
+func (r *tracedMediaRepository) CreateMedia(ctx context.Context, media *model.Media) (err error) {
+	ctx, span := tracing.Start(ctx, "MediaRepository.CreateMedia")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreateMedia(ctx, media)
+}
+
+func (r *tracedMediaRepository) GetMedia(ctx context.Context, id primitive.ObjectID) (result *model.Media, err error) {
+	ctx, span := tracing.Start(ctx, "MediaRepository.GetMedia")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMedia(ctx, id)
+}
+
+func (r *tracedMediaRepository) GetMediaByOwner(ctx context.Context, ownerID primitive.ObjectID, limit int64, skip int64) (result []model.Media, err error) {
+	ctx, span := tracing.Start(ctx, "MediaRepository.GetMediaByOwner")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMediaByOwner(ctx, ownerID, limit, skip)
+}
+
+func (r *tracedMediaRepository) DeleteMedia(ctx context.Context, id primitive.ObjectID, ownerID *primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "MediaRepository.DeleteMedia")
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteMedia(ctx, id, ownerID)
+}
+
+func (r *tracedMediaRepository) KeyInUse(ctx context.Context, key string) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "MediaRepository.KeyInUse")
+	defer func() { tracing.End(span, err) }()
+	return r.next.KeyInUse(ctx, key)
+}
+
+type tracedAdminRepository struct {
+	next AdminRepository
+}
+
+// NewTracedAdminRepository wraps next so each method call is recorded as a span
+func NewTracedAdminRepository(next AdminRepository) AdminRepository {
+	return &tracedAdminRepository{next: next}
+}
+
+func (r *tracedAdminRepository) IsAdmin(ctx context.Context, userID string) (result bool, err error) {
+	ctx, span := tracing.Start(ctx, "AdminRepository.IsAdmin")
+	defer func() { tracing.End(span, err) }()
+	return r.next.IsAdmin(ctx, userID)
+}
@@ -2,11 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"regexp"
 	"time"
 
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
 	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/tracing"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,7 +23,12 @@ type UserRepository interface {
 	GetUsers(ctx context.Context) ([]UserProjection, error)
 	ValidateCredentials(ctx context.Context, username, password string) (*model.User, error)
 	GetUserByUsername(ctx context.Context, username string) (model.User, error)
+	GetUserByImportSource(ctx context.Context, source string) (model.User, error)
 	UpdateUser(ctx context.Context, user model.User) error
+	UpdateNotificationPreferences(ctx context.Context, userID primitive.ObjectID, prefs model.NotificationPreferences) error
+	SetDeletionRequested(ctx context.Context, userID primitive.ObjectID, at *time.Time) error
+	GetUsersPendingDeletion(ctx context.Context, requestedBefore time.Time) ([]model.User, error)
+	DeleteUser(ctx context.Context, userID primitive.ObjectID) error
 }
 
 // UserProjection is a struct used to project only the necessary fields from a user
@@ -29,6 +37,15 @@ type UserProjection struct {
     CreatedAt time.Time `bson:"createdAt,omitempty" json:"createdAt,omitempty"`
 }
 
+// Returned by CreateUser when another account already has the username or email
+var (
+	ErrUsernameTaken = errors.New("username already exists")
+	ErrEmailTaken    = errors.New("email already registered")
+)
+
+// Matches the index named in a duplicate key error, as in "index: email dup key"
+var dupKeyIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+
 type userRepository struct {
 	db *mongo.Collection
 }
@@ -39,6 +56,15 @@ func NewUserRepository(db *mongo.Database) UserRepository {
 	}
 }
 
+// Indexes on the users collection. Unique usernames also stop two concurrent
+// registrations from creating the same account.
+var userIndexes = []Index{
+	{Name: "username", Keys: bson.D{{Key: "username", Value: 1}}, Unique: true},
+	{Name: "email", Keys: bson.D{{Key: "email", Value: 1}}, Unique: true},
+	{Name: "importSource", Keys: bson.D{{Key: "importSource", Value: 1}}, Unique: true, Sparse: true},
+	{Name: "deletionRequestedAt", Keys: bson.D{{Key: "deletionRequestedAt", Value: 1}}, Sparse: true},
+}
+
 // Inserts a new user into the database
 func (r *userRepository) CreateUser(ctx context.Context, user model.User) error {
 	// Validate required fields
@@ -53,9 +79,11 @@ func (r *userRepository) CreateUser(ctx context.Context, user model.User) error
 	}
 
 	// Hash the password
+	_, span := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	span.End()
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
+		logging.FromContext(ctx).Error("Failed to hash password", "error", err)
 		return err
 	}
 	user.HashedPassword = string(hashedPassword)
@@ -70,20 +98,27 @@ func (r *userRepository) CreateUser(ctx context.Context, user model.User) error
 
 	// Insert the user into the database
 	result, err := r.db.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		// The unique indexes catch registrations racing each other
+		if m := dupKeyIndexPattern.FindStringSubmatch(err.Error()); m != nil && m[1] == "email" {
+			return fmt.Errorf("%w: %w", ErrEmailTaken, err)
+		}
+		return fmt.Errorf("%w: %w", ErrUsernameTaken, err)
+	}
 	if err != nil {
-		log.Printf("Error inserting user into database: %v", err)
+		logging.FromContext(ctx).Error("Failed to insert user", "error", err)
 		return err
 	}
 
 	// Type assert the InsertedID and update the user's ID
 	oid, ok := result.InsertedID.(primitive.ObjectID)
 	if !ok {
-		log.Printf("Failed to assert InsertedID to ObjectID")
+		logging.FromContext(ctx).Error("Inserted user ID is not an ObjectID", "inserted_id", result.InsertedID)
 		return fmt.Errorf("failed to assert InsertedID to ObjectID")
 	}
 	user.ID = oid
 
-	log.Printf("Inserted user with ID: %v", user.ID.Hex())
+	logging.FromContext(ctx).Info("Created user", "user_id", user.ID.Hex())
 	return nil
 }
 
@@ -127,27 +162,26 @@ func (r *userRepository) GetUsers(ctx context.Context) ([]UserProjection, error)
 
 // ValidateCredentials checks a user's username and password against the stored values
 func (r *userRepository) ValidateCredentials(ctx context.Context, username, password string) (*model.User, error) {
-    log.Printf("Attempting to validate credentials for username: %s", username)
-
     var user model.User
     err := r.db.FindOne(ctx, bson.M{"username": username}).Decode(&user)
     if err != nil {
         if err == mongo.ErrNoDocuments {
-            log.Printf("No user found for username: %s", username)
+            logging.FromContext(ctx).Debug("Credentials rejected: unknown username")
             return nil, fmt.Errorf("no user found with the given username")
         }
-        log.Printf("Error retrieving user from database: %v", err)
+        logging.FromContext(ctx).Error("Failed to look up user for login", "error", err)
         return nil, err
     }
 
-    log.Printf("User found in database: %s, validating password", username)
     // Compare the stored hashed password with the provided password
-    if err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password)); err != nil {
-        log.Printf("Password validation failed for user: %s", username)
+    _, span := tracing.Start(ctx, "bcrypt.CompareHashAndPassword")
+    err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(password))
+    span.End()
+    if err != nil {
+        logging.FromContext(ctx).Debug("Credentials rejected: wrong password", "user_id", user.ID.Hex())
         return nil, fmt.Errorf("invalid password")
     }
 
-    log.Printf("Credentials validated successfully for user: %s", username)
     return &user, nil
 }
 
@@ -163,12 +197,20 @@ func (r *userRepository) GetUserByUsername(ctx context.Context, username string)
     return user, nil
 }
 
+// Returns the user an import created for the source's author, or mongo.ErrNoDocuments
+func (r *userRepository) GetUserByImportSource(ctx context.Context, source string) (model.User, error) {
+    var user model.User
+    err := r.db.FindOne(ctx, bson.M{"importSource": source}).Decode(&user)
+    return user, err
+}
+
 func (r *userRepository) UpdateUser(ctx context.Context, user model.User) error {
     user.UpdatedAt = time.Now()
     update := bson.M{
         "$set": bson.M{
             "bio":           user.Bio,
             "profilePicUrl": user.ProfilePicURL,
+            "profilePicMediaId": user.ProfilePicMediaID,
             "updatedAt":     user.UpdatedAt,
         },
     }
@@ -176,10 +218,71 @@ func (r *userRepository) UpdateUser(ctx context.Context, user model.User) error
     filter := bson.M{"_id": user.ID}
     _, err := r.db.UpdateOne(ctx, filter, update)
     if err != nil {
-        log.Printf("Error updating user: %v", err)
+        logging.FromContext(ctx).Error("Failed to update user", "user_id", user.ID.Hex(), "error", err)
+        return err
+    }
+
+    logging.FromContext(ctx).Info("Updated user", "user_id", user.ID.Hex())
+    return nil
+}
+
+func (r *userRepository) UpdateNotificationPreferences(ctx context.Context, userID primitive.ObjectID, prefs model.NotificationPreferences) error {
+    update := bson.M{
+        "$set": bson.M{
+            "notificationPrefs": prefs,
+            "updatedAt":         time.Now(),
+        },
+    }
+
+    result, err := r.db.UpdateOne(ctx, bson.M{"_id": userID}, update)
+    if err != nil {
         return err
     }
+    if result.MatchedCount == 0 {
+        return fmt.Errorf("user not found")
+    }
+    return nil
+}
+
+// Schedules the user's account for deletion, or cancels a pending deletion when at is nil
+func (r *userRepository) SetDeletionRequested(ctx context.Context, userID primitive.ObjectID, at *time.Time) error {
+    update := bson.M{"$unset": bson.M{"deletionRequestedAt": ""}}
+    if at != nil {
+        update = bson.M{"$set": bson.M{"deletionRequestedAt": *at}}
+    }
+
+    result, err := r.db.UpdateOne(ctx, bson.M{"_id": userID}, update)
+    if err != nil {
+        return err
+    }
+    if result.MatchedCount == 0 {
+        return fmt.Errorf("user not found")
+    }
+    return nil
+}
 
-    log.Printf("Updated user with ID: %v", user.ID.Hex())
+// Returns users who asked for their account to be deleted before the given time
+func (r *userRepository) GetUsersPendingDeletion(ctx context.Context, requestedBefore time.Time) ([]model.User, error) {
+    cur, err := r.db.Find(ctx, bson.M{"deletionRequestedAt": bson.M{"$lte": requestedBefore}})
+    if err != nil {
+        return nil, err
+    }
+    var users []model.User
+    if err := cur.All(ctx, &users); err != nil {
+        return nil, err
+    }
+    return users, nil
+}
+
+// Removes the user document itself; the user's content is handled separately
+func (r *userRepository) DeleteUser(ctx context.Context, userID primitive.ObjectID) error {
+    result, err := r.db.DeleteOne(ctx, bson.M{"_id": userID})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        return fmt.Errorf("user not found")
+    }
+    logging.FromContext(ctx).Info("Deleted user", "user_id", userID.Hex())
     return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,144 @@
+package seo
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/feed"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+)
+
+// Search engines show roughly this many characters of a description
+const descriptionLength = 160
+
+var (
+	tagPattern        = regexp.MustCompile(`<[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	titlePattern      = regexp.MustCompile(`(?is)<title>.*?</title>`)
+	headClosePattern  = regexp.MustCompile(`(?i)</head>`)
+)
+
+// Meta is everything injected into the SPA shell's <head> for a post page
+type Meta struct {
+	SiteName    string
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	Author      string
+	Published   string
+	Tags        []string
+	JSONLD      map[string]interface{}
+}
+
+var headTemplate = template.Must(template.New("head").Parse(`<title>{{.Title}} | {{.SiteName}}</title>
+    <meta name="description" content="{{.Description}}" />
+    <link rel="canonical" href="{{.URL}}" />
+    <meta property="og:type" content="article" />
+    <meta property="og:site_name" content="{{.SiteName}}" />
+    <meta property="og:title" content="{{.Title}}" />
+    <meta property="og:description" content="{{.Description}}" />
+    <meta property="og:url" content="{{.URL}}" />
+{{- if .Image}}
+    <meta property="og:image" content="{{.Image}}" />
+{{- end}}
+    <meta property="article:published_time" content="{{.Published}}" />
+    <meta property="article:author" content="{{.Author}}" />
+{{- range .Tags}}
+    <meta property="article:tag" content="{{.}}" />
+{{- end}}
+    <meta name="twitter:card" content="{{if .Image}}summary_large_image{{else}}summary{{end}}" />
+    <meta name="twitter:title" content="{{.Title}}" />
+    <meta name="twitter:description" content="{{.Description}}" />
+{{- if .Image}}
+    <meta name="twitter:image" content="{{.Image}}" />
+{{- end}}
+    <script type="application/ld+json" nonce="__CSP_NONCE__">{{.JSONLD}}</script>
+  `))
+
+// PostMeta builds the page metadata for a post
+func PostMeta(post model.Post, siteURL, siteName string) Meta {
+	postURL := feed.PostURL(siteURL, post)
+	description := Describe(post.Content)
+	published := post.PublishedAt.UTC().Format(time.RFC3339)
+
+	jsonLD := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         post.Title,
+		"description":      description,
+		"url":              postURL,
+		"mainEntityOfPage": postURL,
+		"datePublished":    published,
+		"author": map[string]string{
+			"@type": "Person",
+			"name":  post.AuthorUsername,
+		},
+		"publisher": map[string]string{
+			"@type": "Organization",
+			"name":  siteName,
+		},
+	}
+	if len(post.Tags) > 0 {
+		jsonLD["keywords"] = strings.Join(post.Tags, ", ")
+	}
+
+	var image string
+	if post.CoverImage != "" {
+		image = siteURL + post.CoverImage
+		jsonLD["image"] = image
+	}
+
+	return Meta{
+		SiteName:    siteName,
+		Image:       image,
+		Title:       post.Title,
+		Description: description,
+		URL:         postURL,
+		Author:      post.AuthorUsername,
+		Published:   published,
+		Tags:        post.Tags,
+		JSONLD:      jsonLD,
+	}
+}
+
+// Describe turns post content into a short plain-text description
+func Describe(content string) string {
+	text := html.UnescapeString(tagPattern.ReplaceAllString(content, " "))
+	text = strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+	runes := []rune(text)
+	if len(runes) <= descriptionLength {
+		return text
+	}
+	cut := string(runes[:descriptionLength])
+	if i := strings.LastIndex(cut, " "); i > descriptionLength/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// Inject renders meta into the SPA shell, replacing its <title> and adding
+// the rest of the tags just before </head>
+func Inject(shell []byte, meta Meta) ([]byte, error) {
+	var head bytes.Buffer
+	if err := headTemplate.Execute(&head, meta); err != nil {
+		return nil, err
+	}
+
+	page := titlePattern.ReplaceAll(shell, nil)
+	loc := headClosePattern.FindIndex(page)
+	if loc == nil {
+		return append(head.Bytes(), page...), nil
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(page) + head.Len())
+	out.Write(page[:loc[0]])
+	out.Write(head.Bytes())
+	out.Write(page[loc[0]:])
+	return out.Bytes(), nil
+}
@@ -0,0 +1,43 @@
+package seo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/feed"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+)
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap renders a sitemap listing the home page and every post
+func Sitemap(siteURL string, posts []model.Post) ([]byte, error) {
+	set := urlSet{URLs: []sitemapURL{{Loc: siteURL + "/"}}}
+	for _, post := range posts {
+		entry := sitemapURL{Loc: feed.PostURL(siteURL, post)}
+		if !post.PublishedAt.IsZero() {
+			entry.LastMod = post.PublishedAt.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// Robots renders robots.txt, keeping crawlers out of the API and pointing them at the sitemap
+func Robots(siteURL string) []byte {
+	return []byte(fmt.Sprintf("User-agent: *\nAllow: /\nDisallow: /api/\n\nSitemap: %s/sitemap.xml\n", siteURL))
+}
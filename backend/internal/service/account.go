@@ -0,0 +1,266 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"github.com/DavAnders/odin-blogapi/backend/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccountService exports everything stored about a user and carries out account deletions
+// once their grace period has passed
+type AccountService struct {
+	users         repository.UserRepository
+	posts         repository.PostRepository
+	comments      repository.CommentRepository
+	reactions     repository.ReactionRepository
+	follows       repository.FollowRepository
+	notifications repository.NotificationRepository
+	media         repository.MediaRepository
+	storage       storage.Storage
+	gracePeriod   time.Duration
+}
+
+func NewAccountService(users repository.UserRepository, posts repository.PostRepository, comments repository.CommentRepository, reactions repository.ReactionRepository, follows repository.FollowRepository, notifications repository.NotificationRepository, media repository.MediaRepository, storage storage.Storage, gracePeriod time.Duration) *AccountService {
+	return &AccountService{
+		users:         users,
+		posts:         posts,
+		comments:      comments,
+		reactions:     reactions,
+		follows:       follows,
+		notifications: notifications,
+		media:         media,
+		storage:       storage,
+		gracePeriod:   gracePeriod,
+	}
+}
+
+// GracePeriod is how long a deletion request can still be cancelled
+func (s *AccountService) GracePeriod() time.Duration {
+	return s.gracePeriod
+}
+
+// Export writes a ZIP archive of the user's profile and content to w. Each kind of
+// record is a JSON file; the original of every upload is included under media/.
+func (s *AccountService) Export(ctx context.Context, userID primitive.ObjectID, w io.Writer) error {
+	user, err := s.users.GetUser(ctx, userID.Hex())
+	if err != nil {
+		return err
+	}
+	posts, err := s.posts.GetPosts(ctx, bson.M{"authorId": userID}, 0, 0)
+	if err != nil {
+		return err
+	}
+	comments, err := s.comments.GetCommentsByAuthor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	ownComments := make([]model.OwnComment, len(comments))
+	for i, comment := range comments {
+		ownComments[i] = comment.OwnerView()
+	}
+	reactions, err := s.reactions.GetReactionsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	following, err := s.follows.GetFollowing(ctx, userID, 0, 0)
+	if err != nil {
+		return err
+	}
+	followers, err := s.follows.GetFollowers(ctx, userID, 0, 0)
+	if err != nil {
+		return err
+	}
+	notifications, err := s.notifications.GetNotifications(ctx, userID, false, 0, 0)
+	if err != nil {
+		return err
+	}
+	media, err := s.media.GetMediaByOwner(ctx, userID, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", user},
+		{"posts.json", posts},
+		{"comments.json", ownComments},
+		{"reactions.json", reactions},
+		{"following.json", following},
+		{"followers.json", followers},
+		{"notifications.json", notifications},
+		{"media.json", media},
+	}
+	for _, file := range files {
+		if err := writeJSON(archive, file.name, file.data); err != nil {
+			return err
+		}
+	}
+	for _, m := range media {
+		if err := s.copyMedia(ctx, archive, m); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// Copies an upload's original file into the archive, skipping files missing from storage
+func (s *AccountService) copyMedia(ctx context.Context, archive *zip.Writer, media model.Media) error {
+	object, err := s.storage.Open(ctx, media.Key)
+	if err == storage.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	f, err := archive.Create(fmt.Sprintf("media/%s%s", media.ID.Hex(), path.Ext(media.Key)))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, object)
+	return err
+}
+
+// RequestDeletion schedules the account for deletion and returns when it will happen
+func (s *AccountService) RequestDeletion(ctx context.Context, userID primitive.ObjectID) (time.Time, error) {
+	now := time.Now()
+	if err := s.users.SetDeletionRequested(ctx, userID, &now); err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(s.gracePeriod), nil
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (s *AccountService) CancelDeletion(ctx context.Context, userID primitive.ObjectID) error {
+	return s.users.SetDeletionRequested(ctx, userID, nil)
+}
+
+// PurgeDue deletes every account whose grace period has run out and returns how many were removed.
+// A failure on one account is logged and the rest are still processed.
+func (s *AccountService) PurgeDue(ctx context.Context) (int, error) {
+	users, err := s.users.GetUsersPendingDeletion(ctx, time.Now().Add(-s.gracePeriod))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, user := range users {
+		if err := s.Purge(ctx, user.ID); err != nil {
+			logging.FromContext(ctx).Error("Failed to delete account", "user_id", user.ID.Hex(), "error", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Purge removes the user and their content. Their posts, and the comments on them, are deleted;
+// comments they left on other posts are re-attributed to the deleted user placeholder.
+func (s *AccountService) Purge(ctx context.Context, userID primitive.ObjectID) error {
+	// Reactions go first so the counters on posts and comments are kept in step
+	reactions, err := s.reactions.GetReactionsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, reaction := range reactions {
+		if _, err := s.reactions.RemoveReaction(ctx, reaction.TargetType, reaction.TargetID, userID, reaction.Type); err != nil {
+			return err
+		}
+	}
+
+	posts, err := s.posts.GetPosts(ctx, bson.M{"authorId": userID}, 0, 0)
+	if err != nil {
+		return err
+	}
+	postIDs := make([]primitive.ObjectID, 0, len(posts))
+	for _, post := range posts {
+		if err := s.posts.DeletePost(ctx, post.ID.Hex(), nil); err != nil {
+			return err
+		}
+		postIDs = append(postIDs, post.ID)
+	}
+	// Reactions and notifications on the deleted posts and their comments would
+	// otherwise point at nothing
+	commentIDs, err := s.comments.GetCommentIDsByPosts(ctx, postIDs)
+	if err != nil {
+		return err
+	}
+	if _, err := s.reactions.DeleteReactionsByTargets(ctx, model.ReactionTargetComment, commentIDs); err != nil {
+		return err
+	}
+	if _, err := s.reactions.DeleteReactionsByTargets(ctx, model.ReactionTargetPost, postIDs); err != nil {
+		return err
+	}
+	if _, err := s.notifications.DeleteNotificationsByPosts(ctx, postIDs); err != nil {
+		return err
+	}
+	if _, err := s.comments.DeleteCommentsByPosts(ctx, postIDs); err != nil {
+		return err
+	}
+	if _, err := s.comments.AnonymizeComments(ctx, userID); err != nil {
+		return err
+	}
+
+	if _, err := s.follows.DeleteFollowsByUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := s.notifications.DeleteNotificationsByUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.purgeMedia(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.users.DeleteUser(ctx, userID)
+}
+
+// Deletes the user's uploads along with any stored file no other upload shares
+func (s *AccountService) purgeMedia(ctx context.Context, userID primitive.ObjectID) error {
+	media, err := s.media.GetMediaByOwner(ctx, userID, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, m := range media {
+		if err := s.media.DeleteMedia(ctx, m.ID, nil); err != nil {
+			return err
+		}
+		keys := []string{m.Key}
+		for _, variant := range m.Variants {
+			keys = append(keys, variant.Key)
+		}
+		for _, key := range keys {
+			if inUse, err := s.media.KeyInUse(ctx, key); err != nil || inUse {
+				continue
+			}
+			if err := s.storage.Delete(ctx, key); err != nil && err != storage.ErrNotFound {
+				logging.FromContext(ctx).Error("Failed to delete stored file", "key", key, "error", err)
+			}
+		}
+	}
+	return nil
+}
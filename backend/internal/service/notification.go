@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/logging"
+	"github.com/DavAnders/odin-blogapi/backend/internal/model"
+	"github.com/DavAnders/odin-blogapi/backend/internal/realtime"
+	"github.com/DavAnders/odin-blogapi/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxMentions caps how many users a single post or comment can notify by @mention
+const maxMentions = 10
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// NotificationService creates notifications in response to writes made through the controllers.
+// Failures are logged rather than returned so that a notification problem never fails the write itself.
+type NotificationService struct {
+	notifications repository.NotificationRepository
+	users         repository.UserRepository
+	posts         repository.PostRepository
+	comments      repository.CommentRepository
+	events        *realtime.Hub
+}
+
+func NewNotificationService(notifications repository.NotificationRepository, users repository.UserRepository, posts repository.PostRepository, comments repository.CommentRepository, events *realtime.Hub) *NotificationService {
+	return &NotificationService{
+		notifications: notifications,
+		users:         users,
+		posts:         posts,
+		comments:      comments,
+		events:        events,
+	}
+}
+
+// CommentCreated notifies the post's author about a new comment and anyone mentioned in it
+func (s *NotificationService) CommentCreated(ctx context.Context, comment model.Comment) {
+	notified := map[primitive.ObjectID]bool{}
+
+	post, err := s.posts.GetPostByID(ctx, comment.PostID.Hex())
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load post for comment notification", "post_id", comment.PostID.Hex(), "error", err)
+	} else {
+		s.notify(ctx, model.Notification{
+			UserID:        post.AuthorID,
+			Type:          model.NotificationComment,
+			ActorID:       comment.AuthorID,
+			ActorUsername: comment.Author,
+			PostID:        comment.PostID,
+			CommentID:     comment.ID,
+		})
+		notified[post.AuthorID] = true
+	}
+
+	s.notifyMentions(ctx, comment.Content, notified, model.Notification{
+		ActorID:       comment.AuthorID,
+		ActorUsername: comment.Author,
+		PostID:        comment.PostID,
+		CommentID:     comment.ID,
+	})
+}
+
+// PostCreated notifies anyone mentioned in a new post
+func (s *NotificationService) PostCreated(ctx context.Context, post model.Post) {
+	s.notifyMentions(ctx, post.Content, map[primitive.ObjectID]bool{}, model.Notification{
+		ActorID:       post.AuthorID,
+		ActorUsername: post.AuthorUsername,
+		PostID:        post.ID,
+	})
+}
+
+// ReactionAdded notifies the author of the post or comment that received the reaction
+func (s *NotificationService) ReactionAdded(ctx context.Context, reaction model.Reaction) {
+	notification := model.Notification{
+		Type:          model.NotificationReaction,
+		ActorID:       reaction.UserID,
+		ActorUsername: reaction.Username,
+		ReactionType:  reaction.Type,
+	}
+
+	switch reaction.TargetType {
+	case model.ReactionTargetPost:
+		post, err := s.posts.GetPostByID(ctx, reaction.TargetID.Hex())
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to load post for reaction notification", "post_id", reaction.TargetID.Hex(), "error", err)
+			return
+		}
+		notification.UserID = post.AuthorID
+		notification.PostID = post.ID
+	case model.ReactionTargetComment:
+		comment, err := s.comments.GetCommentByID(ctx, reaction.TargetID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to load comment for reaction notification", "comment_id", reaction.TargetID.Hex(), "error", err)
+			return
+		}
+		notification.UserID = comment.AuthorID
+		notification.PostID = comment.PostID
+		notification.CommentID = comment.ID
+	default:
+		return
+	}
+
+	s.notify(ctx, notification)
+}
+
+// Sends a mention notification built from base to every user @mentioned in content who is not in notified
+func (s *NotificationService) notifyMentions(ctx context.Context, content string, notified map[primitive.ObjectID]bool, base model.Notification) {
+	for _, username := range parseMentions(content) {
+		user, err := s.users.GetUserByUsername(ctx, username)
+		if err != nil {
+			continue // Not a user, just an @ in the text
+		}
+		if notified[user.ID] {
+			continue
+		}
+		notified[user.ID] = true
+
+		notification := base
+		notification.UserID = user.ID
+		notification.Type = model.NotificationMention
+		s.notify(ctx, notification)
+	}
+}
+
+// Stores a notification unless it is for the actor themselves or the recipient opted out
+func (s *NotificationService) notify(ctx context.Context, notification model.Notification) {
+	if notification.UserID.IsZero() || notification.UserID == notification.ActorID {
+		return
+	}
+
+	recipient, err := s.users.GetUser(ctx, notification.UserID.Hex())
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load notification recipient", "user_id", notification.UserID.Hex(), "error", err)
+		return
+	}
+	if !recipient.NotificationPreferences().Allows(notification.Type) {
+		return
+	}
+
+	if err := s.notifications.CreateNotification(ctx, &notification); err != nil {
+		logging.FromContext(ctx).Error("Failed to create notification", "type", notification.Type, "user_id", notification.UserID.Hex(), "error", err)
+		return
+	}
+	if err := s.events.Publish(realtime.UserTopic(notification.UserID.Hex()), "notification", notification); err != nil {
+		logging.FromContext(ctx).Error("Failed to publish notification event", "error", err)
+	}
+}
+
+// Returns the distinct usernames @mentioned in content, in order of appearance
+func parseMentions(content string) []string {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,59 @@
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxLength keeps slugs readable in URLs; longer titles are cut at a word boundary
+const maxLength = 80
+
+// Make turns a title into a lowercase, hyphen-separated, ASCII-only slug.
+// Accents are stripped ("Crème brûlée" becomes "creme-brulee"). Returns "post" when
+// nothing usable is left so that every post still gets a slug.
+func Make(title string) string {
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), title)
+	if err != nil {
+		stripped = title
+	}
+
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(stripped) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+
+	s := b.String()
+	if len(s) > maxLength {
+		s = s[:maxLength]
+		if i := strings.LastIndexByte(s, '-'); i > maxLength/2 {
+			s = s[:i]
+		}
+		s = strings.TrimRight(s, "-")
+	}
+	if s == "" {
+		return "post"
+	}
+	return s
+}
+
+// WithSuffix returns base with a numeric suffix, used to make a slug unique ("title-2", "title-3", ...)
+func WithSuffix(base string, n int) string {
+	if n <= 1 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(n)
+}
@@ -0,0 +1,256 @@
+// Package spa serves the built frontend. Every file is read into memory when the
+// handler is created, so requests never touch the disk; a rebuilt frontend is picked up
+// on restart.
+package spa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DavAnders/odin-blogapi/backend/internal/csp"
+)
+
+// Cache policies. Vite puts a content hash in the names of the files it writes to
+// assets/, so those never change; anything else is revalidated with its ETag.
+const (
+	cacheImmutable   = "public, max-age=31536000, immutable"
+	cacheRevalidate  = "no-cache"
+	indexFile        = "index.html"
+	minCompressBytes = 1024 // Smaller files gain too little to be worth compressing
+)
+
+// Matches Vite's hashed file names, such as index-BGd5oZp1.js
+var hashedName = regexp.MustCompile(`-[A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// Content types worth compressing; images, fonts and video are compressed already
+var compressibleTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// Handler serves files from the frontend build, and index.html for any path outside
+// assets/ that isn't a file, so the client-side router can handle it
+type Handler struct {
+	files map[string]*file
+	index *file
+}
+
+type file struct {
+	name         string
+	modTime      time.Time
+	contentType  string
+	cacheControl string
+	identity     variant
+	brotli       *variant
+	gzip         *variant
+}
+
+// One encoding of a file's content
+type variant struct {
+	data []byte
+	etag string
+}
+
+// New loads every file in fsys. Precompressed name.br and name.gz files are sent in
+// place of name to browsers that accept them; other compressible files are gzipped
+// here once.
+func New(fsys fs.FS) (*Handler, error) {
+	contents := map[string][]byte{}
+	modTimes := map[string]time.Time{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Without a frontend build only the fallback's 404 is served
+			if name == "." && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		contents[name] = data
+		if info, err := d.Info(); err == nil {
+			modTimes[name] = info.ModTime() // Zero for embedded files, so no Last-Modified is sent
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{files: make(map[string]*file)}
+	for name, data := range contents {
+		// A precompressed copy is served as an encoding of its original, not on its own
+		if base, ok := strings.CutSuffix(name, ".br"); ok && contents[base] != nil {
+			continue
+		}
+		if base, ok := strings.CutSuffix(name, ".gz"); ok && contents[base] != nil {
+			continue
+		}
+
+		f := &file{
+			name:         name,
+			modTime:      modTimes[name],
+			contentType:  contentType(name, data),
+			cacheControl: cacheRevalidate,
+			identity:     newVariant(data, ""),
+		}
+		if strings.HasPrefix(name, "assets/") && hashedName.MatchString(path.Base(name)) {
+			f.cacheControl = cacheImmutable
+		}
+		if br, ok := contents[name+".br"]; ok {
+			v := newVariant(br, "br")
+			f.brotli = &v
+		}
+		if gz, ok := contents[name+".gz"]; ok {
+			v := newVariant(gz, "gzip")
+			f.gzip = &v
+		} else if compressible(f.contentType) && len(data) >= minCompressBytes {
+			gz, err := gzipBytes(data)
+			if err != nil {
+				return nil, fmt.Errorf("compressing %s: %w", name, err)
+			}
+			if len(gz) < len(data) {
+				v := newVariant(gz, "gzip")
+				f.gzip = &v
+			}
+		}
+		h.files[name] = f
+	}
+	h.index = h.files[indexFile]
+	return h, nil
+}
+
+// ServeHTTP serves the file at the request path, or index.html if there is none
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	f, ok := h.files[name]
+	if !ok {
+		// A missing hashed asset is usually a page loaded before a deploy; HTML in its
+		// place would only fail to parse as a script
+		if strings.HasPrefix(name, "assets/") {
+			http.NotFound(w, r)
+			return
+		}
+		f = h.index
+	}
+	if f == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	// Pages carrying a nonce differ on every request, so they are neither compressed
+	// nor given an ETag
+	if nonce := csp.Nonce(r.Context()); nonce != "" && strings.HasPrefix(f.contentType, "text/html") {
+		header.Set("Content-Type", f.contentType)
+		header.Set("Cache-Control", cacheRevalidate)
+		w.Write(csp.Inject(f.identity.data, nonce))
+		return
+	}
+
+	v, encoding := f.negotiate(r.Header.Get("Accept-Encoding"))
+	header.Set("Content-Type", f.contentType)
+	header.Set("Cache-Control", f.cacheControl)
+	if f.brotli != nil || f.gzip != nil {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	// ServeContent answers If-None-Match and If-Modified-Since with 304 using these
+	header.Set("ETag", v.etag)
+	http.ServeContent(w, r, f.name, f.modTime, bytes.NewReader(v.data))
+}
+
+// Picks the smallest encoding the client accepts, preferring brotli
+func (f *file) negotiate(acceptEncoding string) (variant, string) {
+	accepted := parseAcceptEncoding(acceptEncoding)
+	if f.brotli != nil && accepted["br"] {
+		return *f.brotli, "br"
+	}
+	if f.gzip != nil && accepted["gzip"] {
+		return *f.gzip, "gzip"
+	}
+	return f.identity, ""
+}
+
+// Returns the codings an Accept-Encoding header allows, leaving out those with q=0
+func parseAcceptEncoding(header string) map[string]bool {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[coding] = q > 0
+	}
+	return accepted
+}
+
+// Each encoding gets its own ETag, since the bytes differ
+func newVariant(data []byte, encoding string) variant {
+	etag := fmt.Sprintf("%x", sha256.Sum256(data))
+	if encoding != "" {
+		etag += "-" + encoding
+	}
+	return variant{data: data, etag: `"` + etag + `"`}
+}
+
+func contentType(name string, data []byte) string {
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		return ct
+	}
+	return http.DetectContentType(data)
+}
+
+func compressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
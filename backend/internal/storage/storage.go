@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("object not found")
+
+// Object is an opened stored file
+type Object interface {
+	io.ReadSeekCloser
+	ModTime() time.Time
+	Size() int64
+}
+
+// Storage keeps uploaded files. Keys are slash-separated relative paths chosen by the caller.
+type Storage interface {
+	Save(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+}
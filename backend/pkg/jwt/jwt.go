@@ -1,7 +1,7 @@
 package jwt
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
@@ -11,6 +11,9 @@ import (
 
 var jwtKey = []byte(os.Getenv("SECRET_KEY"))
 
+// TokenLifetime is how long a token, and the session cookie holding it, stays valid
+const TokenLifetime = time.Hour
+
 type Claims struct {
     Username string `json:"username"`
 	UserID string `json:"userId"`
@@ -20,7 +23,8 @@ type Claims struct {
 func getJWTKey() []byte {
     secretKey := os.Getenv("SECRET_KEY")
     if secretKey == "" {
-        log.Fatal("SECRET_KEY is not set or is empty")
+        slog.Error("SECRET_KEY is not set or is empty")
+        os.Exit(1)
     }
     return []byte(secretKey)
 }
@@ -28,7 +32,7 @@ func getJWTKey() []byte {
 // Generates a new JWT token
 func GenerateToken(user model.User) (string, error) {
     jwtKey := getJWTKey()
-    expirationTime := time.Now().Add(1 * time.Hour) // Token expires after 1 hour
+    expirationTime := time.Now().Add(TokenLifetime)
     claims := &Claims{
         Username: user.Username,
 		UserID: user.ID.Hex(), // Convert ObjectID to string
@@ -50,10 +54,8 @@ func ValidateToken(tokenString string) (*jwt.Token, error) {
         return getJWTKey(), nil
     })
 
-    if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-        log.Printf("User %s logged in", claims.Username)
-    } else {
-        log.Printf("Invalid token: %v", err)
+    if _, ok := token.Claims.(*Claims); !ok || !token.Valid {
+        slog.Debug("Invalid token", "error", err)
         return nil, err
     }
 
@@ -4,8 +4,13 @@ import { useNavigate } from "react-router-dom";
 
 function ProfilePage() {
   const { isAuthenticated, logout } = useContext(AuthContext);
-  const [profile, setProfile] = useState({ bio: "", profilePicUrl: "" });
+  const [profile, setProfile] = useState({
+    bio: "",
+    profilePicUrl: "",
+    profilePicMediaId: null,
+  });
   const [loading, setLoading] = useState(true);
+  const [uploading, setUploading] = useState(false);
   const [error, setError] = useState("");
   const navigate = useNavigate();
 
@@ -33,6 +38,7 @@ function ProfilePage() {
           setProfile({
             bio: data.bio || "",
             profilePicUrl: data.profilePicUrl || "",
+            profilePicMediaId: data.profilePicMediaId || null,
           });
           setLoading(false);
         } else {
@@ -51,6 +57,54 @@ function ProfilePage() {
     fetchProfile();
   }, [isAuthenticated]);
 
+  // Uploads the chosen picture; it becomes the profile picture when the form is saved
+  const handleUploadPicture = async (event) => {
+    const file = event.target.files[0];
+    if (!file) return;
+
+    const formData = new FormData();
+    formData.append("file", file);
+    formData.append("purpose", "avatar");
+
+    setUploading(true);
+    try {
+      const response = await fetch(`${import.meta.env.VITE_API_URL}/api/media`, {
+        method: "POST",
+        headers: {
+          Authorization: `Bearer ${localStorage.getItem("token")}`,
+        },
+        body: formData,
+      });
+
+      if (response.ok) {
+        const media = await response.json();
+        const avatar = (media.variants || []).find(
+          (variant) => variant.name === "avatar256"
+        );
+        setProfile({
+          ...profile,
+          profilePicUrl: avatar ? avatar.url : media.url,
+          profilePicMediaId: media.id,
+        });
+        setError("");
+      } else {
+        const errorText = await response.text();
+        console.error("Failed to upload picture:", errorText);
+        setError(errorText || "Failed to upload picture");
+      }
+    } catch (error) {
+      console.error("Error uploading picture:", error);
+      setError("An unexpected error occurred. Please try again later.");
+    } finally {
+      setUploading(false);
+      event.target.value = "";
+    }
+  };
+
+  // Uploaded pictures are served by the API, so relative URLs need its origin
+  const pictureSrc = (url) =>
+    url.startsWith("/") ? `${import.meta.env.VITE_API_URL}${url}` : url;
+
   const handleUpdateProfile = async (event) => {
     event.preventDefault();
 
@@ -72,6 +126,7 @@ function ProfilePage() {
         setProfile({
           bio: data.bio || "",
           profilePicUrl: data.profilePicUrl || "",
+          profilePicMediaId: data.profilePicMediaId || null,
         });
         setError("");
       } else {
@@ -105,19 +160,42 @@ function ProfilePage() {
             </label>
           </div>
           <div className="form-group">
+            {profile.profilePicUrl && (
+              <img
+                src={pictureSrc(profile.profilePicUrl)}
+                alt="Profile"
+                width={128}
+                height={128}
+              />
+            )}
             <label>
-              Profile Picture URL:
+              Profile Picture:
               <input
-                type="text"
-                value={profile.profilePicUrl}
-                onChange={(e) =>
-                  setProfile({ ...profile, profilePicUrl: e.target.value })
-                }
+                type="file"
+                accept="image/jpeg,image/png,image/gif,image/webp"
+                onChange={handleUploadPicture}
+                disabled={uploading}
               />
             </label>
+            {profile.profilePicUrl && (
+              <button
+                type="button"
+                onClick={() =>
+                  setProfile({
+                    ...profile,
+                    profilePicUrl: "",
+                    profilePicMediaId: null,
+                  })
+                }
+              >
+                Remove Picture
+              </button>
+            )}
           </div>
           <div className="updateButton" style={{ marginBottom: "10px" }}>
-            <button type="submit">Update Profile</button>
+            <button type="submit" disabled={uploading}>
+              Update Profile
+            </button>
           </div>
         </form>
       ) : (
@@ -0,0 +1,688 @@
+// Code generated by "api generate-client" from the OpenAPI document. DO NOT EDIT.
+
+/** Thrown for responses with an error status; the message is the response body */
+export class ApiError extends Error {
+  constructor(status, message) {
+    super(message);
+    this.name = "ApiError";
+    this.status = status;
+  }
+}
+
+const baseURL = import.meta.env.VITE_API_URL || "";
+
+function csrfToken() {
+  const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+  return match ? decodeURIComponent(match[1]) : "";
+}
+
+async function request(method, path, { query, body, form } = {}) {
+  const url = new URL(baseURL + path, window.location.origin);
+  for (const [name, value] of Object.entries(query || {})) {
+    if (value !== undefined && value !== null) url.searchParams.set(name, value);
+  }
+
+  const headers = {};
+  const token = localStorage.getItem("token");
+  if (token) headers.Authorization = `Bearer ${token}`;
+  const csrf = csrfToken();
+  if (csrf && method !== "GET") headers["X-CSRF-Token"] = csrf;
+
+  let payload;
+  if (form) {
+    payload = new FormData();
+    for (const [name, value] of Object.entries(form)) {
+      if (value !== undefined && value !== null) payload.append(name, value);
+    }
+  } else if (body !== undefined) {
+    headers["Content-Type"] = "application/json";
+    payload = JSON.stringify(body);
+  }
+
+  const response = await fetch(url, { method, headers, body: payload, credentials: "include" });
+  if (!response.ok) {
+    throw new ApiError(response.status, (await response.text()).trim());
+  }
+  if ((response.headers.get("Content-Type") || "").includes("application/json")) {
+    return response.json();
+  }
+  return undefined;
+}
+
+/**
+ * @typedef {Object} BuildInfo
+ * @property {string} [buildTime]
+ * @property {string} [commit]
+ * @property {string} [goVersion]
+ * @property {boolean} [modified] - Built with uncommitted changes
+ * @property {string} [version] - Module version; "(devel)" for builds from a working tree
+ */
+
+/**
+ * @typedef {Object} Comment
+ * @property {string} [author] - Username of the author, or "deleted user"
+ * @property {ObjectID} [authorId]
+ * @property {string} [content]
+ * @property {string} [createdAt]
+ * @property {ObjectID} [id]
+ * @property {Array<ReactionType>} [myReactions]
+ * @property {ObjectID} [parentId]
+ * @property {ObjectID} [postId]
+ * @property {ReactionCounts} [reactionCounts]
+ * @property {string} [updatedAt] - When the comment was last edited, or its creation time
+ */
+
+/**
+ * @typedef {Object} CommentInput
+ * @property {string} content
+ * @property {ObjectID} [parentId]
+ * @property {ObjectID} postId
+ */
+
+/**
+ * @typedef {Object} Credentials
+ * @property {string} password
+ * @property {string} username
+ */
+
+/**
+ * Human-readable message followed by a newline
+ * @typedef {string} Error
+ */
+
+/**
+ * @typedef {Object} ErrorObject
+ * @property {string} [error]
+ */
+
+/**
+ * @typedef {Object} Follow
+ * @property {string} [createdAt]
+ * @property {ObjectID} [followeeId]
+ * @property {string} [followeeUsername]
+ * @property {ObjectID} [followerId]
+ * @property {string} [followerUsername]
+ * @property {ObjectID} [id]
+ */
+
+/**
+ * @typedef {Object} HealthStatus
+ * @property {Object<string, ("ok"|"degraded"|"failed")>} [checks] - Result of each check, including `shutdown`. A `degraded` check needs attention, such as an index that couldn't be built, but leaves the instance ready.
+ * @property {("ok"|"unavailable")} [status]
+ */
+
+/**
+ * @typedef {Object} Media
+ * @property {string} [contentType]
+ * @property {string} [createdAt]
+ * @property {string} [filename]
+ * @property {number} [height]
+ * @property {ObjectID} [id]
+ * @property {ObjectID} [ownerId]
+ * @property {number} [size]
+ * @property {string} [url]
+ * @property {Array<MediaVariant>} [variants]
+ * @property {number} [width]
+ */
+
+/**
+ * @typedef {Object} MediaVariant
+ * @property {number} [height]
+ * @property {string} [name]
+ * @property {number} [size]
+ * @property {string} [url]
+ * @property {number} [width]
+ */
+
+/**
+ * @typedef {Object} NewUser
+ * @property {string} email
+ * @property {string} password
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} Notification
+ * @property {ObjectID} [actorId]
+ * @property {string} [actorUsername]
+ * @property {ObjectID} [commentId]
+ * @property {string} [createdAt]
+ * @property {ObjectID} [id]
+ * @property {ObjectID} [postId]
+ * @property {ReactionType} [reactionType]
+ * @property {boolean} [read]
+ * @property {("comment"|"mention"|"reaction")} [type]
+ * @property {ObjectID} [userId]
+ */
+
+/**
+ * @typedef {Object} NotificationPreferences
+ * @property {boolean} [comments]
+ * @property {boolean} [mentions]
+ * @property {boolean} [reactions]
+ */
+
+/**
+ * @typedef {string} ObjectID
+ */
+
+/**
+ * @typedef {Object} Post
+ * @property {ObjectID} [authorId]
+ * @property {string} [authorUsername]
+ * @property {string} [content]
+ * @property {string} [coverImage] - URL of the cover image's large variant
+ * @property {ObjectID} [coverMediaId]
+ * @property {ObjectID} [id]
+ * @property {Array<ReactionType>} [myReactions] - The current user's reactions; only set for authenticated requests
+ * @property {string} [publishedAt]
+ * @property {ReactionCounts} [reactionCounts]
+ * @property {string} [slug]
+ * @property {Array<string>} [tags]
+ * @property {string} [title]
+ */
+
+/**
+ * @typedef {Object} PostInput
+ * @property {string} content
+ * @property {ObjectID} [coverMediaId]
+ * @property {Array<string>} [tags]
+ * @property {string} title
+ */
+
+/**
+ * Returned when a post is created. Same fields as `Post`.
+ * @typedef {Object} PostResponse
+ * @property {ObjectID} [authorId]
+ * @property {string} [authorUsername]
+ * @property {string} [content]
+ * @property {string} [coverImage]
+ * @property {ObjectID} [coverMediaId]
+ * @property {ObjectID} [id]
+ * @property {Array<ReactionType>} [myReactions]
+ * @property {string} [publishedAt]
+ * @property {ReactionCounts} [reactionCounts]
+ * @property {string} [slug]
+ * @property {Array<string>} [tags]
+ * @property {string} [title]
+ */
+
+/**
+ * @typedef {Object} ProfileInput
+ * @property {string} [bio]
+ * @property {ObjectID} [profilePicMediaId]
+ * @property {string} [profilePicUrl] - Must point at an upload under `/media/`, unless it is the URL already stored; ignored when `profilePicMediaId` is set
+ */
+
+/**
+ * @typedef {Object} PublicProfile
+ * @property {boolean} [author]
+ * @property {string} [bio]
+ * @property {string} [createdAt]
+ * @property {ObjectID} [id]
+ * @property {string} [profilePicUrl]
+ * @property {string} [username]
+ */
+
+/**
+ * @typedef {Object} Reaction
+ * @property {string} [createdAt]
+ * @property {ObjectID} [id]
+ * @property {ObjectID} [targetId]
+ * @property {("post"|"comment")} [targetType]
+ * @property {ReactionType} [type]
+ * @property {ObjectID} [userId]
+ * @property {string} [username]
+ */
+
+/**
+ * Number of reactions of each type
+ * @typedef {Object<string, number>} ReactionCounts
+ */
+
+/**
+ * @typedef {("like"|"love"|"laugh"|"wow"|"sad"|"celebrate")} ReactionType
+ */
+
+/**
+ * @typedef {Object} Token
+ * @property {string} [csrfToken] - Value for the X-CSRF-Token header when authenticating with the cookie
+ * @property {string} [token] - JWT to send as a Bearer token
+ */
+
+/**
+ * @typedef {Object} User
+ * @property {boolean} [author]
+ * @property {string} [bio]
+ * @property {string} [createdAt]
+ * @property {string} [deletionRequestedAt] - Set while the account is scheduled for deletion
+ * @property {string} [email]
+ * @property {ObjectID} [id]
+ * @property {NotificationPreferences} [notificationPrefs]
+ * @property {string} [password] - Always empty in responses
+ * @property {ObjectID} [profilePicMediaId]
+ * @property {string} [profilePicUrl]
+ * @property {string} [updatedAt]
+ * @property {string} [username]
+ */
+
+/**
+ * @typedef {Object} UserSummary
+ * @property {string} [createdAt]
+ * @property {string} [username]
+ */
+
+/**
+ * Delete any comment
+ * @param {ObjectID} id
+ * @returns {Promise<void>}
+ */
+export function adminDeleteComment(id) {
+  return request("DELETE", `/api/admin/comments/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Delete any post
+ * @param {ObjectID} id
+ * @returns {Promise<void>}
+ */
+export function adminDeletePost(id) {
+  return request("DELETE", `/api/admin/posts/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Comment on a post
+ * @param {CommentInput} body
+ * @returns {Promise<Comment>}
+ */
+export function createComment(body) {
+  return request("POST", "/api/comments", { body });
+}
+
+/**
+ * List the comments on a post
+ * @param {ObjectID} id
+ * @returns {Promise<Array<Comment>>}
+ */
+export function getCommentsByPost(id) {
+  return request("GET", `/api/comments/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Edit one of the current user's comments
+ * @param {ObjectID} id
+ * @param {CommentInput} body
+ * @returns {Promise<Comment>}
+ */
+export function updateComment(id, body) {
+  return request("PUT", `/api/comments/${encodeURIComponent(id)}`, { body });
+}
+
+/**
+ * Delete one of the current user's comments
+ * @param {ObjectID} id
+ * @returns {Promise<void>}
+ */
+export function deleteComment(id) {
+  return request("DELETE", `/api/comments/${encodeURIComponent(id)}`);
+}
+
+/**
+ * List reactions on a comment
+ * @param {ObjectID} id
+ * @param {{type?: ReactionType, limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Reaction>>}
+ */
+export function getCommentReactions(id, query = {}) {
+  return request("GET", `/api/comments/${encodeURIComponent(id)}/reactions`, { query });
+}
+
+/**
+ * React to a comment
+ * @param {ObjectID} id
+ * @param {ReactionType} type
+ * @returns {Promise<{type?: ReactionType}>}
+ */
+export function addCommentReaction(id, type) {
+  return request("PUT", `/api/comments/${encodeURIComponent(id)}/reactions/${encodeURIComponent(type)}`);
+}
+
+/**
+ * Remove a reaction from a comment
+ * @param {ObjectID} id
+ * @param {ReactionType} type
+ * @returns {Promise<void>}
+ */
+export function removeCommentReaction(id, type) {
+  return request("DELETE", `/api/comments/${encodeURIComponent(id)}/reactions/${encodeURIComponent(type)}`);
+}
+
+/**
+ * Latest posts from the users the current user follows
+ * @param {{limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Post>>}
+ */
+export function getFeed(query = {}) {
+  return request("GET", "/api/feed", { query });
+}
+
+/**
+ * List the current user's uploads, newest first
+ * @param {{limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Media>>}
+ */
+export function getMyMedia(query = {}) {
+  return request("GET", "/api/media", { query });
+}
+
+/**
+ * Upload an image
+ * @param {{file: Blob, purpose?: ("avatar")}} form
+ * @returns {Promise<Media>}
+ */
+export function uploadMedia(form) {
+  return request("POST", "/api/media", { form });
+}
+
+/**
+ * Get the metadata of one of the current user's uploads
+ * @param {ObjectID} id
+ * @returns {Promise<Media>}
+ */
+export function getMedia(id) {
+  return request("GET", `/api/media/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Delete one of the current user's uploads
+ * @param {ObjectID} id
+ * @returns {Promise<void>}
+ */
+export function deleteMedia(id) {
+  return request("DELETE", `/api/media/${encodeURIComponent(id)}`);
+}
+
+/**
+ * List the current user's notifications, newest first
+ * @param {{unread?: boolean, limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Notification>>}
+ */
+export function getNotifications(query = {}) {
+  return request("GET", "/api/notifications", { query });
+}
+
+/**
+ * Mark notifications as read
+ * @param {{ids?: Array<ObjectID>}} [body]
+ * @returns {Promise<{updated?: number}>}
+ */
+export function markNotificationsRead(body) {
+  return request("POST", "/api/notifications/read", { body });
+}
+
+/**
+ * Count the current user's unread notifications
+ * @returns {Promise<{unread?: number}>}
+ */
+export function getUnreadCount() {
+  return request("GET", "/api/notifications/unread-count");
+}
+
+/**
+ * This specification
+ * @returns {Promise<Object>}
+ */
+export function getOpenAPI() {
+  return request("GET", "/api/openapi.json");
+}
+
+/**
+ * List posts, newest first
+ * @param {{limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Post>>}
+ */
+export function getPosts(query = {}) {
+  return request("GET", "/api/posts", { query });
+}
+
+/**
+ * Publish a post as the current user
+ * @param {PostInput} body
+ * @returns {Promise<PostResponse>}
+ */
+export function createPost(body) {
+  return request("POST", "/api/posts", { body });
+}
+
+/**
+ * Get a post by slug
+ * @param {string} slug
+ * @returns {Promise<Post>}
+ */
+export function getPostBySlug(slug) {
+  return request("GET", `/api/posts/by-slug/${encodeURIComponent(slug)}`);
+}
+
+/**
+ * List the current user's latest posts
+ * @param {ObjectID} userID
+ * @returns {Promise<Array<Post>>}
+ */
+export function getPostsByUser(userID) {
+  return request("GET", `/api/posts/user/${encodeURIComponent(userID)}`);
+}
+
+/**
+ * Get a post
+ * @param {string} id
+ * @returns {Promise<Post>}
+ */
+export function getPost(id) {
+  return request("GET", `/api/posts/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Update a post
+ * @param {string} id
+ * @param {PostInput} body
+ * @returns {Promise<Post>}
+ */
+export function updatePost(id, body) {
+  return request("PUT", `/api/posts/${encodeURIComponent(id)}`, { body });
+}
+
+/**
+ * Delete one of the current user's posts
+ * @param {string} id
+ * @returns {Promise<void>}
+ */
+export function deletePost(id) {
+  return request("DELETE", `/api/posts/${encodeURIComponent(id)}`);
+}
+
+/**
+ * List reactions on a post
+ * @param {ObjectID} id
+ * @param {{type?: ReactionType, limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Reaction>>}
+ */
+export function getPostReactions(id, query = {}) {
+  return request("GET", `/api/posts/${encodeURIComponent(id)}/reactions`, { query });
+}
+
+/**
+ * React to a post
+ * @param {ObjectID} id
+ * @param {ReactionType} type
+ * @returns {Promise<{type?: ReactionType}>}
+ */
+export function addPostReaction(id, type) {
+  return request("PUT", `/api/posts/${encodeURIComponent(id)}/reactions/${encodeURIComponent(type)}`);
+}
+
+/**
+ * Remove a reaction from a post
+ * @param {ObjectID} id
+ * @param {ReactionType} type
+ * @returns {Promise<void>}
+ */
+export function removePostReaction(id, type) {
+  return request("DELETE", `/api/posts/${encodeURIComponent(id)}/reactions/${encodeURIComponent(type)}`);
+}
+
+/**
+ * Get the current user's full record
+ * @returns {Promise<User>}
+ */
+export function getProfile() {
+  return request("GET", "/api/profile");
+}
+
+/**
+ * Update the current user's bio and profile picture
+ * @param {ProfileInput} body
+ * @returns {Promise<User>}
+ */
+export function updateProfile(body) {
+  return request("PUT", "/api/profile", { body });
+}
+
+/**
+ * Schedule the current user's account for deletion
+ * @param {{password: string}} body
+ * @returns {Promise<{deleteAt?: string}>}
+ */
+export function deleteProfile(body) {
+  return request("DELETE", "/api/profile", { body });
+}
+
+/**
+ * Get the current user's notification preferences
+ * @returns {Promise<NotificationPreferences>}
+ */
+export function getNotificationPreferences() {
+  return request("GET", "/api/profile/notifications");
+}
+
+/**
+ * Change which notifications the current user receives
+ * @param {NotificationPreferences} body
+ * @returns {Promise<NotificationPreferences>}
+ */
+export function updateNotificationPreferences(body) {
+  return request("PUT", "/api/profile/notifications", { body });
+}
+
+/**
+ * Cancel a pending account deletion
+ * @returns {Promise<void>}
+ */
+export function restoreProfile() {
+  return request("POST", "/api/profile/restore");
+}
+
+/**
+ * List all users
+ * @returns {Promise<Array<UserSummary>>}
+ */
+export function getUsers() {
+  return request("GET", "/api/users");
+}
+
+/**
+ * Create a user without logging in as them
+ * @param {NewUser} body
+ * @returns {Promise<User>}
+ */
+export function createUser(body) {
+  return request("POST", "/api/users", { body });
+}
+
+/**
+ * Get a user's profile
+ * @param {ObjectID} id
+ * @returns {Promise<*>}
+ */
+export function getUser(id) {
+  return request("GET", `/api/users/${encodeURIComponent(id)}`);
+}
+
+/**
+ * Follow a user
+ * @param {ObjectID} id
+ * @returns {Promise<{followeeId?: ObjectID}>}
+ */
+export function follow(id) {
+  return request("POST", `/api/users/${encodeURIComponent(id)}/follow`);
+}
+
+/**
+ * Stop following a user
+ * @param {ObjectID} id
+ * @returns {Promise<void>}
+ */
+export function unfollow(id) {
+  return request("DELETE", `/api/users/${encodeURIComponent(id)}/follow`);
+}
+
+/**
+ * List who follows a user
+ * @param {ObjectID} id
+ * @param {{limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Follow>>}
+ */
+export function getFollowers(id, query = {}) {
+  return request("GET", `/api/users/${encodeURIComponent(id)}/followers`, { query });
+}
+
+/**
+ * List who a user follows
+ * @param {ObjectID} id
+ * @param {{limit?: number, skip?: number}} [query]
+ * @returns {Promise<Array<Follow>>}
+ */
+export function getFollowing(id, query = {}) {
+  return request("GET", `/api/users/${encodeURIComponent(id)}/following`, { query });
+}
+
+/**
+ * Log in with a username and password
+ * @param {Credentials} body
+ * @returns {Promise<Token>}
+ */
+export function login(body) {
+  return request("POST", "/login", { body });
+}
+
+/**
+ * Log out by clearing the session cookies
+ * @returns {Promise<void>}
+ */
+export function logout() {
+  return request("POST", "/logout");
+}
+
+/**
+ * Readiness probe
+ * @returns {Promise<HealthStatus>}
+ */
+export function getReadiness() {
+  return request("GET", "/readyz");
+}
+
+/**
+ * Create an account and log in
+ * @param {NewUser} body
+ * @returns {Promise<Token>}
+ */
+export function register(body) {
+  return request("POST", "/register", { body });
+}
+
+/**
+ * Build information
+ * @returns {Promise<BuildInfo>}
+ */
+export function getVersion() {
+  return request("GET", "/version");
+}